@@ -0,0 +1,41 @@
+package comagic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Account is a balance and usage limits of the account
+type Account struct {
+	Balance     Money  `json:"balance"`
+	CreditLimit Money  `json:"credit_limit"`
+	Currency    string `json:"currency"`
+	Plan        string `json:"tariff_plan_name"`
+	// RequestsLimit is a daily limit of API requests and RequestsUsed
+	// is a number of requests made today
+	RequestsLimit int64 `json:"requests_limit"`
+	RequestsUsed  int64 `json:"requests_used"`
+	// Extra holds response fields unknown to the package
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler interface
+func (a *Account) UnmarshalJSON(b []byte) error {
+	type plain Account
+	if err := json.Unmarshal(b, (*plain)(a)); err != nil {
+		return err
+	}
+	extra, err := unknownFields(b, a)
+	a.Extra = extra
+	return err
+}
+
+// AccountInfo returns current balance and usage limits of the account
+func (c *Client) AccountInfo(ctx context.Context) (Account, error) {
+	account := Account{}
+	if err := c.GetJSON(ctx, "/api/account/", nil, &account); err != nil {
+		return Account{}, fmt.Errorf("account info: %w", err)
+	}
+	return account, nil
+}
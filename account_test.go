@@ -0,0 +1,46 @@
+package comagic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/nk2ge5k/go-api-comagic/comagictest"
+)
+
+func TestAccountInfo(t *testing.T) {
+	srv := comagictest.NewServer(t, comagictest.WithData("/api/account/", json.RawMessage(
+		`{"balance":"1234567890123.4567","credit_limit":0.1,"currency":"RUB","tariff_plan_name":"Business",`+
+			`"requests_limit":10000,"requests_used":1234,"quota_calls":500}`)))
+	c := NewClient(New("login", "password", WithBaseURL(srv.URL())))
+	account, err := c.AccountInfo(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := account.Balance.String(); got != "1234567890123.4567" {
+		t.Errorf("got balance %s, want 1234567890123.4567", got)
+	}
+	if got := account.Balance.Add(account.CreditLimit).String(); got != "1234567890123.5567" {
+		t.Errorf("got balance with credit %s, want exact 1234567890123.5567", got)
+	}
+	if account.Currency != "RUB" || account.Plan != "Business" || account.RequestsLimit != 10000 || account.RequestsUsed != 1234 {
+		t.Errorf("got account %+v", account)
+	}
+	if string(account.Extra["quota_calls"]) != "500" {
+		t.Errorf("got extra %v, want quota kept", account.Extra)
+	}
+}
+
+func TestAccountInfoAPIError(t *testing.T) {
+	srv := comagictest.NewServer(t, comagictest.WithHandler("/api/account/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		comagictest.RespondError(w, "access_denied", "no access to account")
+	})))
+	c := NewClient(New("login", "password", WithBaseURL(srv.URL())))
+	_, err := c.AccountInfo(context.Background())
+	ae := &APIError{}
+	if !errors.As(err, &ae) || ae.Code != "access_denied" {
+		t.Fatalf("got error %v, want access_denied APIError", err)
+	}
+}
@@ -0,0 +1,325 @@
+package comagic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// DefaultLocation is a time zone of report date parameters used when none is set,
+// it is a default time zone of comagic accounts
+var DefaultLocation = loadLocation("Europe/Moscow", 3*60*60)
+
+// DefaultMaxReportPeriod is a maximum report period accepted by API
+const DefaultMaxReportPeriod = 92 * 24 * time.Hour
+
+// Doer sends http requests, it is satisfied by *http.Client
+type Doer interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+// ClientFunc is an adapter allowing to use function as Doer
+type ClientFunc func(*http.Request) (*http.Response, error)
+
+// Do implements Doer interface
+func (f ClientFunc) Do(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// Client is comagic API client sending requests with http client created by New
+// or any other Doer, e.g. fake one in tests
+type Client struct {
+	doer      Doer
+	loc       *time.Location
+	maxPeriod time.Duration
+	cache     *referenceCache
+	jsonCodec JSONCodec
+}
+
+// WithMaxReportPeriod is an option function for setting maximum report period
+// checked before report request is sent, non positive duration disables check
+func WithMaxReportPeriod(d time.Duration) func(*Client) {
+	return func(c *Client) { c.maxPeriod = d }
+}
+
+// WithLocation is an option function for setting account time zone,
+// report date parameters are converted into it before formatting
+func WithLocation(loc *time.Location) func(*Client) {
+	return func(c *Client) {
+		if loc != nil {
+			c.loc = loc
+		}
+	}
+}
+
+// NewClient returns API client making requests with given doer,
+// nil doer means http.DefaultClient
+func NewClient(d Doer, opts ...func(*Client)) *Client {
+	if d == nil {
+		d = http.DefaultClient
+	}
+	c := &Client{doer: d, loc: DefaultLocation, maxPeriod: DefaultMaxReportPeriod}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Close releases resources held by underlying transport
+func (c *Client) Close() error {
+	if t := c.transport(); t != nil {
+		return t.Close()
+	}
+	return nil
+}
+
+// transport returns underlying comagic transport if any
+func (c *Client) transport() *Transport {
+	if hc, ok := c.doer.(*http.Client); ok {
+		if t, ok := hc.Transport.(*Transport); ok {
+			return t
+		}
+	}
+	return nil
+}
+
+// logger returns logger of underlying comagic transport if any
+func (c *Client) logger() *slog.Logger {
+	if t := c.transport(); t != nil {
+		return t.logger
+	}
+	return nil
+}
+
+// Do sends API request and returns data field of response envelope.
+// Relative request URL is resolved against transport base URL.
+// If API reports failure error is an *APIError
+func (c *Client) Do(req *http.Request) (json.RawMessage, error) {
+	res, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	return c.decodeEnvelope(res)
+}
+
+// GetJSON makes GET request to API endpoint path with given query
+// and decodes response data into out, out could be nil if data is not needed.
+// Null or missing data is decoded as empty result, so list is never nil
+func (c *Client) GetJSON(ctx context.Context, path string, query url.Values, out interface{}) error {
+	env, err := c.getEnvelope(ctx, path, query)
+	if err != nil {
+		return err
+	}
+	return c.decodeData(env.Data, out)
+}
+
+// getEnvelope makes GET request to API endpoint and returns response envelope
+func (c *Client) getEnvelope(ctx context.Context, path string, query url.Values) (apiResp, error) {
+	u := &url.URL{Path: path, RawQuery: query.Encode()}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return apiResp{}, fmt.Errorf("could not create request: %v", err)
+	}
+	res, err := c.do(req)
+	if err != nil {
+		return apiResp{}, err
+	}
+	defer res.Body.Close()
+	return c.decodeResponse(res)
+}
+
+// PostJSON makes POST request to API endpoint path with body encoded as JSON
+// and decodes response data into out, out could be nil if data is not needed
+func (c *Client) PostJSON(ctx context.Context, path string, body, out interface{}) error {
+	b, err := c.codec().Marshal(body)
+	if err != nil {
+		return fmt.Errorf("could not encode request body: %v", err)
+	}
+	u := &url.URL{Path: path}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("could not create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	data, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	return c.decodeData(data, out)
+}
+
+// validatePeriod checks report period before request is sent
+func (c *Client) validatePeriod(from, till time.Time) error {
+	if till.Before(from) {
+		return &ValidationError{Field: "period", Message: "date from is after date till"}
+	}
+	if c.maxPeriod > 0 && till.Sub(from) > c.maxPeriod {
+		return &ValidationError{Field: "period", Message: fmt.Sprintf("period exceeds %s", c.maxPeriod)}
+	}
+	return nil
+}
+
+// reportQuery returns query parameters of report period and pagination,
+// zero offset and limit are omitted. Dates are sent in DateTimeLayout
+// in account time zone
+func reportQuery(loc *time.Location, from, till time.Time, offset, limit int) url.Values {
+	v := url.Values{}
+	v.Set("date_from", from.In(loc).Format(DateTimeLayout))
+	v.Set("date_till", till.In(loc).Format(DateTimeLayout))
+	if offset > 0 {
+		v.Set("offset", strconv.Itoa(offset))
+	}
+	if limit > 0 {
+		v.Set("limit", strconv.Itoa(limit))
+	}
+	return v
+}
+
+// decodeEnvelope reads API response envelope and returns its data,
+// body which is not an envelope is reported in error
+func (c *Client) decodeEnvelope(res *http.Response) (json.RawMessage, error) {
+	env, err := c.decodeResponse(res)
+	return env.Data, err
+}
+
+// decodeResponse reads API response envelope,
+// body which is not an envelope is reported in error
+func (c *Client) decodeResponse(res *http.Response) (apiResp, error) {
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return apiResp{}, fmt.Errorf("could not read response: %w", err)
+	}
+	if res.StatusCode == http.StatusTooManyRequests {
+		return apiResp{}, rateLimited(res, time.Now())
+	}
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusMultipleChoices {
+		// bot protection often answers with 403 challenge page
+		if !looksJSON(body) && challenged(res.Header.Get("Content-Type"), body) {
+			return apiResp{}, nonJSON(res, body)
+		}
+		if len(body) > maxErrorSize {
+			body = append([]byte(nil), body[:maxErrorSize]...)
+		}
+		return apiResp{}, &HTTPError{StatusCode: res.StatusCode, Body: body}
+	}
+	if !looksJSON(body) {
+		return apiResp{}, nonJSON(res, body)
+	}
+	env := apiResp{}
+	if err := c.unmarshal(body, &env); err != nil {
+		return apiResp{}, fmt.Errorf("could not decode response: %v: %q", err, snippet(body))
+	}
+	if !env.Success {
+		return apiResp{}, &APIError{Code: env.Code, Message: env.Message}
+	}
+	return env, nil
+}
+
+// decodeData unmarshals envelope data into out. Null or missing data
+// is an empty result: nil slice pointed by out becomes empty one
+// and any other value is left as is
+func (c *Client) decodeData(data json.RawMessage, out interface{}) error {
+	if out == nil {
+		return nil
+	}
+	if d := bytes.TrimSpace(data); len(d) == 0 || bytes.Equal(d, []byte("null")) {
+		emptySlice(out)
+		return nil
+	}
+	if err := c.unmarshal(data, out); err != nil {
+		return fmt.Errorf("could not decode response data: %v", err)
+	}
+	c.localize(out)
+	return nil
+}
+
+var dateTimeType = reflect.TypeOf(DateTime{})
+
+// localize moves DateTime values decoded in DefaultLocation
+// into account time zone keeping their wall clock
+func (c *Client) localize(out interface{}) {
+	if c.loc == DefaultLocation {
+		return
+	}
+	localize(reflect.ValueOf(out), c.loc)
+}
+
+func localize(v reflect.Value, loc *time.Location) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			localize(v.Elem(), loc)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			localize(v.Index(i), loc)
+		}
+	case reflect.Struct:
+		if !v.CanAddr() {
+			return
+		}
+		if v.Type() == dateTimeType {
+			if d := v.Addr().Interface().(*DateTime); !d.IsZero() {
+				y, m, day := d.Date()
+				h, min, s := d.Clock()
+				d.Time = time.Date(y, m, day, h, min, s, d.Nanosecond(), loc)
+			}
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				localize(v.Field(i), loc)
+			}
+		}
+	}
+}
+
+// emptySlice sets nil slice pointed by out to empty one
+func emptySlice(out interface{}) {
+	v := reflect.ValueOf(out)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return
+	}
+	if e := v.Elem(); e.Kind() == reflect.Slice && e.IsNil() {
+		e.Set(reflect.MakeSlice(e.Type(), 0, 0))
+	}
+}
+
+// loadLocation returns named location or fixed zone with given offset
+// if time zone database is not available
+func loadLocation(name string, offset int) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.FixedZone(name, offset)
+	}
+	return loc
+}
+
+// snippetSize is a maximum size of response body included into errors
+const snippetSize = 256
+
+// snippet returns beginning of the body for error messages
+func snippet(body []byte) string {
+	if len(body) > snippetSize {
+		return string(body[:snippetSize]) + "..."
+	}
+	return string(body)
+}
+
+type apiResp struct {
+	Success bool            `json:"success"`
+	Code    string          `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+	// NextPageToken is set by endpoints paginating with tokens
+	NextPageToken string `json:"next_page_token"`
+}
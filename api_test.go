@@ -0,0 +1,237 @@
+package comagic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nk2ge5k/go-api-comagic/comagictest"
+)
+
+func TestReportQueryLocation(t *testing.T) {
+	from := time.Date(2024, 1, 1, 21, 0, 0, 0, time.UTC)
+	till := from.Add(time.Hour)
+	v := reportQuery(DefaultLocation, from, till, 0, 0)
+	if got, want := v.Get("date_from"), "2024-01-02 00:00:00"; got != want {
+		t.Errorf("got date_from %q, want %q", got, want)
+	}
+	if got, want := v.Get("date_till"), "2024-01-02 01:00:00"; got != want {
+		t.Errorf("got date_till %q, want %q", got, want)
+	}
+
+	tokyo := time.FixedZone("Asia/Tokyo", 9*60*60)
+	v = reportQuery(tokyo, from, till, 10, 20)
+	if got, want := v.Get("date_from"), "2024-01-02 06:00:00"; got != want {
+		t.Errorf("got date_from %q, want %q", got, want)
+	}
+	if v.Get("offset") != "10" || v.Get("limit") != "20" {
+		t.Errorf("got offset %q and limit %q, want 10 and 20", v.Get("offset"), v.Get("limit"))
+	}
+}
+
+func TestDateTimeLocation(t *testing.T) {
+	var gotFrom string
+	srv := comagictest.NewServer(t, comagictest.WithHandler("/api/calls_report/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotFrom = r.URL.Query().Get("date_from")
+		comagictest.Respond(w, []map[string]interface{}{{"id": 1, "call_date": "2024-01-01 10:00:00"}})
+	})))
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	till := from.Add(24 * time.Hour)
+
+	for _, loc := range []*time.Location{DefaultLocation, time.UTC, time.FixedZone("UTC+5", 5*60*60)} {
+		c := NewClient(New("login", "password", WithBaseURL(srv.URL())), WithLocation(loc))
+		report, err := c.CallsReport(context.Background(), CallsReportRequest{DateFrom: from, DateTill: till})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", loc, err)
+		}
+		if gotFrom != from.In(loc).Format(DateTimeLayout) {
+			t.Errorf("%s: got date_from %q, want %q", loc, gotFrom, from.In(loc).Format(DateTimeLayout))
+		}
+		callDate := report.Calls[0].CallDate
+		if got := callDate.In(loc).Format(DateTimeLayout); got != "2024-01-01 10:00:00" {
+			t.Errorf("%s: got call date %q in account time zone, want 2024-01-01 10:00:00", loc, got)
+		}
+		if want := time.Date(2024, 1, 1, 10, 0, 0, 0, loc); !callDate.Equal(want) {
+			t.Errorf("%s: got call date %s, want %s", loc, callDate.Time, want)
+		}
+
+		err = c.CallsReportStream(context.Background(), CallsReportRequest{DateFrom: from, DateTill: till}, func(call Call) error {
+			if want := time.Date(2024, 1, 1, 10, 0, 0, 0, loc); !call.CallDate.Equal(want) {
+				t.Errorf("%s: got streamed call date %s, want %s", loc, call.CallDate.Time, want)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%s: unexpected stream error: %v", loc, err)
+		}
+	}
+}
+
+// stubClient returns client sending API requests to stub answering
+// with given status and body, login is answered with session key
+func stubClient(status int, contentType, body string) *Client {
+	return NewClient(New("login", "password", WithTransport(roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if strings.HasSuffix(r.URL.Path, DefaultLoginPath) {
+			return stubResponse(r, http.StatusOK, `{"success":true,"data":{"session_key":"key"}}`), nil
+		}
+		res := stubResponse(r, status, body)
+		res.Header.Set("Content-Type", contentType)
+		return res, nil
+	}))))
+}
+
+func TestClientDo(t *testing.T) {
+	c := stubClient(http.StatusOK, "application/json", `{"success":true,"data":{"n":1}}`)
+	req, _ := http.NewRequest(http.MethodGet, "/api/x/", nil)
+	data, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(data) != `{"n":1}` {
+		t.Errorf("got data %s, want data field of envelope", data)
+	}
+}
+
+func TestClientDoAPIError(t *testing.T) {
+	c := stubClient(http.StatusOK, "application/json", `{"success":false,"code":"invalid_param","message":"limit is too large"}`)
+	req, _ := http.NewRequest(http.MethodGet, "/api/x/", nil)
+	_, err := c.Do(req)
+	ae := &APIError{}
+	if !errors.As(err, &ae) {
+		t.Fatalf("got error %v, want APIError", err)
+	}
+	if ae.Code != "invalid_param" || ae.Message != "limit is too large" {
+		t.Errorf("got API error %+v", ae)
+	}
+}
+
+func TestClientDoNotEnvelope(t *testing.T) {
+	c := stubClient(http.StatusOK, "application/json", `{"success":tr`)
+	req, _ := http.NewRequest(http.MethodGet, "/api/x/", nil)
+	_, err := c.Do(req)
+	if err == nil || !strings.Contains(err.Error(), `{\"success\":tr`) {
+		t.Fatalf("got error %v, want body in error", err)
+	}
+}
+
+func TestGetJSON(t *testing.T) {
+	srv := comagictest.NewServer(t, comagictest.WithHandler("/api/x/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Query().Get("site_id") != "7" || r.URL.Query().Get("q") != "a b" {
+			t.Errorf("got request %s %s, want GET with query", r.Method, r.URL)
+		}
+		comagictest.Respond(w, map[string]string{"name": "site"})
+	})))
+	c := NewClient(New("login", "password", WithBaseURL(srv.URL())))
+	out := struct {
+		Name string `json:"name"`
+	}{}
+	if err := c.GetJSON(context.Background(), "/api/x/", url.Values{"site_id": {"7"}, "q": {"a b"}}, &out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out.Name != "site" {
+		t.Errorf("got name %q, want site", out.Name)
+	}
+}
+
+func TestPostJSON(t *testing.T) {
+	srv := comagictest.NewServer(t, comagictest.WithHandler("/api/x/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := map[string]int{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || r.Method != http.MethodPost || body["n"] != 2 {
+			t.Errorf("got request %s with body %v (%v), want POST with n=2", r.Method, body, err)
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("got content type %q, want application/json", ct)
+		}
+		comagictest.Respond(w, map[string]int{"id": 3})
+	})))
+	c := NewClient(New("login", "password", WithBaseURL(srv.URL())))
+	out := map[string]int{}
+	if err := c.PostJSON(context.Background(), "/api/x/", map[string]int{"n": 2}, &out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out["id"] != 3 {
+		t.Errorf("got %v, want id=3", out)
+	}
+	if err := c.PostJSON(context.Background(), "/api/x/", map[string]int{"n": 2}, nil); err != nil {
+		t.Fatalf("got error %v, want response data ignored", err)
+	}
+}
+
+func TestEmptyData(t *testing.T) {
+	for _, body := range []string{`{"success":true,"data":null}`, `{"success":true,"data":[]}`, `{"success":true}`} {
+		c := stubClient(http.StatusOK, "application/json", body)
+		tags, err := c.Tags(context.Background())
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", body, err)
+		}
+		if tags == nil || len(tags) != 0 {
+			t.Errorf("%s: got tags %v, want empty list", body, tags)
+		}
+		from := time.Now().Add(-time.Hour)
+		calls, err := c.CallsReport(context.Background(), CallsReportRequest{DateFrom: from, DateTill: from.Add(time.Minute)})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", body, err)
+		}
+		if calls.Calls == nil || len(calls.Calls) != 0 {
+			t.Errorf("%s: got calls %v, want empty list", body, calls.Calls)
+		}
+		var items []int
+		if err := c.GetJSON(context.Background(), "/api/x/", nil, &items); err != nil {
+			t.Fatalf("%s: unexpected error: %v", body, err)
+		}
+		if items == nil || len(items) != 0 {
+			t.Errorf("%s: got %v, want empty slice", body, items)
+		}
+	}
+
+	c := stubClient(http.StatusOK, "application/json", `{"success":true,"data":null}`)
+	out := map[string]int{"n": 1}
+	if err := c.GetJSON(context.Background(), "/api/x/", nil, &out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out["n"] != 1 {
+		t.Errorf("got %v, want non slice value left as is", out)
+	}
+}
+
+func TestValidatePeriod(t *testing.T) {
+	var requests atomic.Int32
+	doer := ClientFunc(func(r *http.Request) (*http.Response, error) {
+		requests.Add(1)
+		return stubResponse(r, http.StatusOK, `{"success":true,"data":[]}`), nil
+	})
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		opts     []func(*Client)
+		from     time.Time
+		till     time.Time
+		rejected bool
+	}{
+		{nil, from, from.AddDate(0, 1, 0), false},
+		{nil, from, from, false},
+		{nil, from, from.Add(-time.Second), true},
+		{nil, from, from.Add(DefaultMaxReportPeriod + time.Second), true},
+		{[]func(*Client){WithMaxReportPeriod(24 * time.Hour)}, from, from.Add(25 * time.Hour), true},
+		{[]func(*Client){WithMaxReportPeriod(365 * 24 * time.Hour)}, from, from.AddDate(0, 6, 0), false},
+		{[]func(*Client){WithMaxReportPeriod(0)}, from, from.AddDate(2, 0, 0), false},
+	}
+	for _, tt := range tests {
+		requests.Store(0)
+		c := NewClient(doer, tt.opts...)
+		_, err := c.CallsReport(context.Background(), CallsReportRequest{DateFrom: tt.from, DateTill: tt.till})
+		ve := &ValidationError{}
+		if got := errors.As(err, &ve); got != tt.rejected {
+			t.Errorf("period %s - %s: got error %v, want rejected %v", tt.from, tt.till, err, tt.rejected)
+		}
+		if tt.rejected && (ve.Field != "period" || requests.Load() != 0) {
+			t.Errorf("period %s - %s: got error of %q after %d requests, want period rejected before request",
+				tt.from, tt.till, ve.Field, requests.Load())
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package comagic
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// attemptKey is a context key of request attempt counter
+type attemptKey struct{}
+
+// AttemptFromContext returns number of the current attempt to send request
+// with given context: 1 for the first send, increased by every retry,
+// re-authorization replay and failover. Zero is returned for context
+// of request not sent by Transport
+func AttemptFromContext(ctx context.Context) int {
+	if n, ok := ctx.Value(attemptKey{}).(*atomic.Int32); ok {
+		return int(n.Load())
+	}
+	return 0
+}
+
+// withAttempts returns context carrying a new attempt counter
+func withAttempts(ctx context.Context) context.Context {
+	return context.WithValue(ctx, attemptKey{}, new(atomic.Int32))
+}
+
+// nextAttempt increases attempt counter of the context if any
+func nextAttempt(ctx context.Context) {
+	if n, ok := ctx.Value(attemptKey{}).(*atomic.Int32); ok {
+		n.Add(1)
+	}
+}
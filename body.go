@@ -0,0 +1,57 @@
+package comagic
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// ErrBodyNotRewindable is returned when request has to be replayed
+// but its body could not be obtained again
+var ErrBodyNotRewindable = errors.New("request body could not be rewound")
+
+// rewindable makes sure that request body can be obtained again via GetBody,
+// body without GetBody is buffered in memory
+func rewindable(r *http.Request) error {
+	if !hasBody(r) || r.GetBody != nil {
+		return nil
+	}
+	if err := r.Context().Err(); err != nil {
+		return err
+	}
+	b, err := io.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return err
+	}
+	r.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(b)), nil
+	}
+	r.Body, _ = r.GetBody()
+	return nil
+}
+
+// rewind replaces consumed request body with a fresh one, it fails
+// if request is cancelled or body could not be obtained again
+func rewind(r *http.Request) error {
+	if err := r.Context().Err(); err != nil {
+		return err
+	}
+	if !hasBody(r) {
+		return nil
+	}
+	if r.GetBody == nil {
+		return ErrBodyNotRewindable
+	}
+	body, err := r.GetBody()
+	if err != nil {
+		return err
+	}
+	r.Body = body
+	return nil
+}
+
+func hasBody(r *http.Request) bool {
+	return r.Body != nil && r.Body != http.NoBody
+}
@@ -0,0 +1,84 @@
+package comagic
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/nk2ge5k/go-api-comagic/comagictest"
+)
+
+func TestMultipartReplay(t *testing.T) {
+	var files []string
+	srv := comagictest.NewServer(t, comagictest.WithHandler("/api/upload/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, _, err := r.FormFile("file")
+		if err != nil {
+			t.Errorf("invalid multipart body: %v", err)
+			return
+		}
+		b, _ := io.ReadAll(f)
+		files = append(files, r.FormValue("name")+" "+string(b))
+		comagictest.Respond(w, nil)
+	})))
+	tr := New("login", "password", WithBaseURL(srv.URL())).Transport.(*Transport)
+	if err := tr.Authenticate(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	srv.ExpireSession()
+
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	w.WriteField("name", "report")
+	fw, _ := w.CreateFormFile("file", "report.csv")
+	fw.Write([]byte("id,duration\n1,60\n"))
+	w.Close()
+	// reader which could not be rewound by net/http
+	req, _ := http.NewRequest(http.MethodPost, "/api/upload/", io.MultiReader(body))
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	res, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	res.Body.Close()
+	if len(files) != 1 || files[0] != "report id,duration\n1,60\n" {
+		t.Fatalf("got uploads %q, want multipart body replayed after re-authorization", files)
+	}
+	if srv.Logins() != 2 {
+		t.Errorf("got %d logins, want re-authorization", srv.Logins())
+	}
+}
+
+func TestRewind(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPost, "/", io.MultiReader(strings.NewReader("payload")))
+	if err := rewind(req); !errors.Is(err, ErrBodyNotRewindable) {
+		t.Fatalf("got error %v, want ErrBodyNotRewindable", err)
+	}
+	if err := rewindable(req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := rewind(req); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if b, _ := io.ReadAll(req.Body); string(b) != "payload" {
+			t.Fatalf("got body %q after rewind, want payload", b)
+		}
+	}
+
+	empty, _ := http.NewRequest(http.MethodGet, "/", nil)
+	if err := rewind(empty); err != nil {
+		t.Errorf("got error %v of request without body", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req = req.WithContext(ctx)
+	if err := rewind(req); !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want cancelled request not rewound", err)
+	}
+}
@@ -0,0 +1,117 @@
+package comagic
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned when circuit breaker does not allow requests
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitBreaker decides whether requests could be sent to API
+type CircuitBreaker interface {
+	// Allow reports whether request could be sent now
+	Allow() bool
+	// Record reports result of allowed request, failure is true for
+	// server errors and connection failures
+	Record(failure bool)
+	// Cancel reports that allowed request was cancelled by the caller,
+	// it tells nothing about API health
+	Cancel()
+}
+
+// WithCircuitBreaker is an option function for setting circuit breaker consulted
+// before every outgoing request including authorization and retries.
+// Requests not allowed by breaker fail with ErrCircuitOpen without being sent
+func WithCircuitBreaker(cb CircuitBreaker) func(*Transport) {
+	return func(t *Transport) { t.breaker = cb }
+}
+
+// Breaker is a CircuitBreaker opening after given number of consecutive
+// failures. Open breaker allows single trial request after cooldown,
+// its success closes breaker and failure opens it again
+type Breaker struct {
+	failures int
+	cooldown time.Duration
+	now      func() time.Time
+
+	mu       sync.Mutex
+	count    int
+	openedAt time.Time
+	trial    bool
+}
+
+// NewCircuitBreaker returns breaker opening after failures consecutive failures
+// for cooldown, non positive failures means 1
+func NewCircuitBreaker(failures int, cooldown time.Duration) *Breaker {
+	if failures <= 0 {
+		failures = 1
+	}
+	return &Breaker{failures: failures, cooldown: cooldown, now: time.Now}
+}
+
+// Allow implements CircuitBreaker interface
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.count < b.failures {
+		return true
+	}
+	if b.trial || b.now().Sub(b.openedAt) < b.cooldown {
+		return false
+	}
+	b.trial = true
+	return true
+}
+
+// Record implements CircuitBreaker interface
+func (b *Breaker) Record(failure bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+	if !failure {
+		b.count = 0
+		return
+	}
+	b.count++
+	if b.count >= b.failures {
+		b.openedAt = b.now()
+	}
+}
+
+// Cancel implements CircuitBreaker interface, it allows another trial
+// request without changing number of failures
+func (b *Breaker) Cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+}
+
+// Open reports whether breaker is open
+func (b *Breaker) Open() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.count >= b.failures
+}
+
+// exchange sends request with underlying transport if circuit breaker allows it
+func (t *Transport) exchange(r *http.Request) (*http.Response, error) {
+	if t.breaker == nil {
+		return t.transport().RoundTrip(r)
+	}
+	if !t.breaker.Allow() {
+		return nil, ErrCircuitOpen
+	}
+	res, err := t.transport().RoundTrip(r)
+	switch {
+	case err != nil && r.Context().Err() != nil:
+		t.breaker.Cancel()
+	case err != nil:
+		t.breaker.Record(true)
+	default:
+		t.breaker.Record(res.StatusCode >= http.StatusInternalServerError)
+	}
+	return res, err
+}
@@ -0,0 +1,118 @@
+package comagic
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nk2ge5k/go-api-comagic/comagictest"
+)
+
+func TestCircuitBreakerFastFail(t *testing.T) {
+	var requests atomic.Int32
+	srv := comagictest.NewServer(t, comagictest.WithHandler("/api/x/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})))
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cb := NewCircuitBreaker(2, time.Minute)
+	cb.now = func() time.Time { return now }
+	c := New("login", "password", WithBaseURL(srv.URL()), WithCircuitBreaker(cb))
+
+	get := func() error {
+		req, _ := http.NewRequest(http.MethodGet, "/api/x/", nil)
+		res, err := c.Do(req)
+		if err == nil {
+			res.Body.Close()
+		}
+		return err
+	}
+	for i := 0; i < 2; i++ {
+		if err := get(); err != nil {
+			t.Fatalf("request %d: unexpected error: %v", i, err)
+		}
+	}
+	if !cb.Open() {
+		t.Fatal("breaker is closed after 2 failures")
+	}
+	if err := get(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("got error %v, want ErrCircuitOpen", err)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Fatalf("got %d requests, open breaker must not send any", n)
+	}
+
+	// trial request after cooldown fails and opens breaker again
+	now = now.Add(time.Minute)
+	if err := get(); err != nil {
+		t.Fatalf("trial request: unexpected error: %v", err)
+	}
+	if n := requests.Load(); n != 3 {
+		t.Fatalf("got %d requests, want trial request to be sent", n)
+	}
+	if err := get(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("got error %v after failed trial, want ErrCircuitOpen", err)
+	}
+}
+
+func TestCircuitBreakerCancelledTrial(t *testing.T) {
+	block := make(chan struct{})
+	srv := comagictest.NewServer(t, comagictest.WithHandler("/api/x/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-block:
+		case <-r.Context().Done():
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})))
+	defer close(block)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cb := NewCircuitBreaker(2, time.Minute)
+	cb.now = func() time.Time { return now }
+	cb.Record(true)
+	cb.Record(true)
+	now = now.Add(time.Minute)
+
+	c := New("login", "password", WithBaseURL(srv.URL()), WithCircuitBreaker(cb))
+	if err := c.Transport.(*Transport).Authenticate(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// authorization was the trial request and closed breaker, open it again
+	cb.Record(true)
+	cb.Record(true)
+	now = now.Add(time.Minute)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "/api/x/", nil)
+	if _, err := c.Do(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got error %v, want context deadline", err)
+	}
+	if !cb.Open() {
+		t.Fatal("cancelled trial request closed breaker")
+	}
+	if !cb.Allow() {
+		t.Fatal("cancelled trial request did not release trial")
+	}
+}
+
+func TestBreakerRecord(t *testing.T) {
+	cb := NewCircuitBreaker(3, time.Minute)
+	cb.Record(true)
+	cb.Record(true)
+	cb.Record(false)
+	cb.Record(true)
+	cb.Record(true)
+	if cb.Open() {
+		t.Fatal("success must reset consecutive failures")
+	}
+	cb.Record(true)
+	if !cb.Open() {
+		t.Fatal("breaker is closed after 3 consecutive failures")
+	}
+	if cb.Allow() {
+		t.Fatal("open breaker allowed request before cooldown")
+	}
+}
@@ -0,0 +1,122 @@
+package comagic
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Config is an alternative to option functions for complex setups,
+// zero value of every field keeps the default
+type Config struct {
+	// Login and Password are user credentials, mutually exclusive with Token
+	Login    string
+	Password string
+	// Token is an access token used instead of login and password
+	Token string
+	// Credentials provides login and password on every authorization,
+	// mutually exclusive with Login, Password and Token
+	Credentials CredentialProvider
+
+	// BaseURL overrides DefaultBaseURL, FallbackURLs are tried after it
+	// on server errors and connection failures
+	BaseURL      *url.URL
+	FallbackURLs []*url.URL
+
+	// Transport is an underlying transport, mutually exclusive with TLSConfig and Proxy
+	Transport http.RoundTripper
+	TLSConfig *tls.Config
+	Proxy     *url.URL
+
+	// Timeout limits whole request of the built http.Client
+	Timeout time.Duration
+
+	// AuthRetries is a number of replays on expired session,
+	// zero means DefaultAuthRetries and negative disables replays
+	AuthRetries int
+	// AuthTimeout limits authorization request
+	AuthTimeout time.Duration
+
+	Retry       *RetryPolicy
+	RateLimiter Limiter
+	Breaker     CircuitBreaker
+	Logger      *slog.Logger
+	UserAgent   string
+
+	// Options are applied after the fields, so they take precedence
+	Options []func(*Transport)
+}
+
+// Build validates configuration and returns http client making requests
+// with Transport configured by it. Conflicting settings are reported as error
+func (c Config) Build() (*http.Client, error) {
+	opts := c.options()
+	t := &Transport{}
+	for _, opt := range opts {
+		opt(t)
+	}
+	t.Login = c.Login
+	t.Password = c.Password
+	if err := t.validate(); err != nil {
+		return nil, fmt.Errorf("build: %v", err)
+	}
+	return New(c.Login, c.Password, opts...), nil
+}
+
+// options returns option functions equivalent to the config
+func (c Config) options() []func(*Transport) {
+	var opts []func(*Transport)
+	if len(c.Token) > 0 {
+		opts = append(opts, WithToken(c.Token))
+	}
+	if c.Credentials != nil {
+		opts = append(opts, WithCredentialProvider(c.Credentials))
+	}
+	if c.BaseURL != nil || len(c.FallbackURLs) > 0 {
+		base := c.BaseURL
+		if base == nil {
+			base = DefaultBaseURL
+		}
+		opts = append(opts, WithBaseURLs(append([]*url.URL{base}, c.FallbackURLs...)...))
+	}
+	if c.Timeout > 0 {
+		opts = append(opts, WithClientTimeout(c.Timeout))
+	}
+	if c.Transport != nil {
+		opts = append(opts, WithTransport(c.Transport))
+	}
+	if c.TLSConfig != nil {
+		opts = append(opts, WithTLSConfig(c.TLSConfig))
+	}
+	if c.Proxy != nil {
+		opts = append(opts, WithProxy(c.Proxy))
+	}
+	switch {
+	case c.AuthRetries < 0:
+		opts = append(opts, WithAuthRetries(0))
+	case c.AuthRetries > 0:
+		opts = append(opts, WithAuthRetries(c.AuthRetries))
+	}
+	if c.AuthTimeout > 0 {
+		opts = append(opts, WithAuthTimeout(c.AuthTimeout))
+	}
+	if c.Retry != nil {
+		opts = append(opts, WithRetry(*c.Retry))
+	}
+	if c.RateLimiter != nil {
+		opts = append(opts, WithRateLimiter(c.RateLimiter))
+	}
+	if c.Breaker != nil {
+		opts = append(opts, WithCircuitBreaker(c.Breaker))
+	}
+	if c.Logger != nil {
+		opts = append(opts, WithLogger(c.Logger))
+	}
+	if len(c.UserAgent) > 0 {
+		opts = append(opts, WithUserAgent(c.UserAgent))
+	}
+	return append(opts, c.Options...)
+}
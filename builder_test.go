@@ -0,0 +1,67 @@
+package comagic
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nk2ge5k/go-api-comagic/comagictest"
+)
+
+func TestConfigBuildConflicts(t *testing.T) {
+	proxy, _ := url.Parse("http://proxy.local:3128")
+	tests := []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{"token and login", Config{Login: "login", Password: "password", Token: "token"}, "token and login/password"},
+		{"token and provider", Config{Token: "token", Credentials: StaticCredentials{}}, "token and credential provider"},
+		{"provider and login", Config{Login: "login", Credentials: StaticCredentials{}}, "credential provider and login/password"},
+		{"transport and TLS", Config{Login: "login", Transport: http.DefaultTransport, TLSConfig: &tls.Config{}}, "TLS config"},
+		{"transport and proxy", Config{Login: "login", Transport: http.DefaultTransport, Proxy: proxy}, "proxy"},
+		{"invalid base URL", Config{Login: "login", BaseURL: &url.URL{Host: "api.comagic.ru"}}, "has no scheme"},
+		{"invalid fallback URL", Config{Login: "login", FallbackURLs: []*url.URL{{Scheme: "https"}}}, "has no host"},
+	}
+	for _, tt := range tests {
+		c, err := tt.cfg.Build()
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got error %v, want %q", tt.name, err, tt.want)
+		}
+		if c != nil {
+			t.Errorf("%s: got client of invalid config", tt.name)
+		}
+	}
+}
+
+func TestConfigBuild(t *testing.T) {
+	srv := comagictest.NewServer(t, comagictest.WithCredentials("login", "password"), comagictest.WithData("/api/x/", nil))
+	c, err := Config{
+		Login:     "login",
+		Password:  "password",
+		BaseURL:   srv.URL(),
+		Timeout:   time.Minute,
+		UserAgent: "reports/1.0",
+		Retry:     &RetryPolicy{MaxAttempts: 2},
+		Options:   []func(*Transport){WithUserAgent("reports/2.0")},
+	}.Build()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.Timeout != time.Minute {
+		t.Errorf("got client timeout %s, want 1m", c.Timeout)
+	}
+	tr := c.Transport.(*Transport)
+	if tr.userAgent != "reports/2.0" || tr.retry == nil || tr.retry.MaxAttempts != 2 {
+		t.Errorf("got user agent %q and retry %+v, want options applied after fields", tr.userAgent, tr.retry)
+	}
+	req, _ := http.NewRequest(http.MethodGet, "/api/x/", nil)
+	res, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	res.Body.Close()
+}
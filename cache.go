@@ -0,0 +1,105 @@
+package comagic
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// WithReferenceCache is an option function enabling in-memory cache of
+// reference data like sites, virtual numbers, employees and tags: results are
+// reused for ttl since they were fetched. Data of accounts given with
+// WithCredentialsContext is cached separately. Non positive ttl disables cache
+func WithReferenceCache(ttl time.Duration) func(*Client) {
+	return func(c *Client) {
+		if ttl <= 0 {
+			c.cache = nil
+			return
+		}
+		c.cache = &referenceCache{ttl: ttl, now: time.Now, entries: map[cacheKey]cacheEntry{}}
+	}
+}
+
+// InvalidateCache drops all cached reference data
+func (c *Client) InvalidateCache() {
+	if c.cache != nil {
+		c.cache.reset()
+	}
+}
+
+// referenceCache holds response data of reference endpoints
+// keyed by endpoint path, query and account
+type referenceCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[cacheKey]cacheEntry
+}
+
+// cacheKey identifies cached response, credentials of the request context
+// are part of the key so accounts served by one client never share data
+type cacheKey struct {
+	credentials
+	request string
+}
+
+type cacheEntry struct {
+	env     apiResp
+	expires time.Time
+}
+
+func (rc *referenceCache) get(key cacheKey) (apiResp, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	e, ok := rc.entries[key]
+	if !ok {
+		return apiResp{}, false
+	}
+	if !rc.now().Before(e.expires) {
+		delete(rc.entries, key)
+		return apiResp{}, false
+	}
+	return e.env, true
+}
+
+func (rc *referenceCache) put(key cacheKey, env apiResp) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.entries[key] = cacheEntry{env: env, expires: rc.now().Add(rc.ttl)}
+}
+
+func (rc *referenceCache) reset() {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.entries = map[cacheKey]cacheEntry{}
+}
+
+// getReference is like GetJSON but serves response data from cache if enabled,
+// data is decoded on every call so callers never share results
+func (c *Client) getReference(ctx context.Context, path string, query url.Values, out interface{}) error {
+	env, err := c.getReferenceEnvelope(ctx, path, query)
+	if err != nil {
+		return err
+	}
+	return c.decodeData(env.Data, out)
+}
+
+// getReferenceEnvelope is like getEnvelope but serves envelope from cache if enabled
+func (c *Client) getReferenceEnvelope(ctx context.Context, path string, query url.Values) (apiResp, error) {
+	if c.cache == nil {
+		return c.getEnvelope(ctx, path, query)
+	}
+	key := cacheKey{request: path + "?" + query.Encode()}
+	key.credentials, _ = ctx.Value(credentialsKey{}).(credentials)
+	if env, ok := c.cache.get(key); ok {
+		return env, nil
+	}
+	env, err := c.getEnvelope(ctx, path, query)
+	if err != nil {
+		return env, err
+	}
+	c.cache.put(key, env)
+	return env, nil
+}
@@ -0,0 +1,152 @@
+package comagic
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nk2ge5k/go-api-comagic/comagictest"
+)
+
+func TestReferenceCache(t *testing.T) {
+	var requests atomic.Int32
+	srv := comagictest.NewServer(t, comagictest.WithHandler("/api/tags/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		comagictest.Respond(w, []map[string]interface{}{{"id": 1, "name": "lead"}})
+	})))
+	const ttl = time.Minute
+	c := NewClient(New("login", "password", WithBaseURL(srv.URL())), WithReferenceCache(ttl))
+	now := time.Now()
+	c.cache.now = func() time.Time { return now }
+	ctx := context.Background()
+	tags := func() []Tag {
+		t.Helper()
+		tags, err := c.Tags(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return tags
+	}
+
+	first := tags()
+	first[0].Name = "changed"
+	if got := tags(); got[0].Name != "lead" {
+		t.Errorf("got cached tag %q, want results not shared between calls", got[0].Name)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Fatalf("got %d requests, want cache hit", n)
+	}
+
+	now = now.Add(ttl - time.Second)
+	tags()
+	if n := requests.Load(); n != 1 {
+		t.Fatalf("got %d requests, want cache hit before expiration", n)
+	}
+	now = now.Add(time.Second)
+	tags()
+	if n := requests.Load(); n != 2 {
+		t.Fatalf("got %d requests, want expired entry re-fetched", n)
+	}
+
+	c.InvalidateCache()
+	tags()
+	if n := requests.Load(); n != 3 {
+		t.Fatalf("got %d requests, want invalidated entry re-fetched", n)
+	}
+}
+
+func TestReferenceCacheDisabled(t *testing.T) {
+	var requests atomic.Int32
+	srv := comagictest.NewServer(t, comagictest.WithHandler("/api/tags/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			comagictest.RespondError(w, "internal_error", "try later")
+			return
+		}
+		comagictest.Respond(w, []interface{}{})
+	})))
+	for _, tt := range []struct {
+		opts []func(*Client)
+		want int32
+	}{
+		{nil, 3},
+		{[]func(*Client){WithReferenceCache(time.Hour)}, 2},
+	} {
+		requests.Store(0)
+		c := NewClient(New("login", "password", WithBaseURL(srv.URL())), tt.opts...)
+		// failed response is not cached
+		if _, err := c.Tags(context.Background()); err == nil {
+			t.Fatal("got no error of API error response")
+		}
+		for i := 0; i < 2; i++ {
+			if _, err := c.Tags(context.Background()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		if n := requests.Load(); n != tt.want {
+			t.Errorf("cache %v: got %d requests, want %d", c.cache != nil, n, tt.want)
+		}
+	}
+}
+
+func TestReferenceCacheTenants(t *testing.T) {
+	var requests atomic.Int32
+	stub := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Path == DefaultLoginPath {
+			if err := r.ParseMultipartForm(1 << 10); err != nil {
+				t.Errorf("invalid login request: %v", err)
+			}
+			return stubResponse(r, http.StatusOK, `{"success":true,"data":{"session_key":"`+r.FormValue("login")+`"}}`), nil
+		}
+		requests.Add(1)
+		// every account has its own tag
+		key := r.URL.Query().Get("session_key")
+		return stubResponse(r, http.StatusOK, `{"success":true,"data":[{"id":1,"name":"`+key+`"}]}`), nil
+	})
+	c := NewClient(New("default", "password", WithTransport(stub)), WithReferenceCache(time.Hour))
+	for i := 0; i < 2; i++ {
+		for _, tt := range []struct {
+			ctx  context.Context
+			want string
+		}{
+			{WithCredentialsContext(context.Background(), "first", "password"), "first"},
+			{WithCredentialsContext(context.Background(), "second", "password"), "second"},
+			{context.Background(), "default"},
+		} {
+			tags, err := c.Tags(tt.ctx)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(tags) != 1 || tags[0].Name != tt.want {
+				t.Errorf("got tags %+v, want tag of %s account", tags, tt.want)
+			}
+		}
+	}
+	if n := requests.Load(); n != 3 {
+		t.Errorf("got %d requests, want one per account", n)
+	}
+}
+
+func TestReferenceCacheConcurrent(t *testing.T) {
+	srv := comagictest.NewServer(t, comagictest.WithData("/api/tags/", []interface{}{}))
+	c := NewClient(New("login", "password", WithBaseURL(srv.URL())), WithReferenceCache(time.Millisecond))
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				if _, err := c.Tags(context.Background()); err != nil {
+					t.Error(err)
+					return
+				}
+				if i == 0 {
+					c.InvalidateCache()
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,64 @@
+package comagic
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CallRequestInput is a call request (callback) parameters
+type CallRequestInput struct {
+	// Phone is a contact phone number called back, required
+	Phone string
+	// CampaignID is an advertising campaign call is attributed to
+	CampaignID int64
+	// ScheduledAt is a time to make the call, zero means as soon as possible
+	ScheduledAt time.Time
+}
+
+// CallRequestResult is a created call request
+type CallRequestResult struct {
+	ID     int64  `json:"call_request_id"`
+	Status string `json:"status"`
+}
+
+// CreateCallRequest creates call request making API call back given phone.
+// Invalid input is reported as *ValidationError before request is sent
+// and rejection by API as *APIError
+func (c *Client) CreateCallRequest(ctx context.Context, in CallRequestInput) (CallRequestResult, error) {
+	res := CallRequestResult{}
+	if err := in.validate(); err != nil {
+		return res, fmt.Errorf("create call request: %w", err)
+	}
+	body := callRequestBody{Phone: in.Phone, CampaignID: in.CampaignID}
+	if !in.ScheduledAt.IsZero() {
+		body.ScheduledTime = in.ScheduledAt.In(c.loc).Format(DateTimeLayout)
+	}
+	if err := c.PostJSON(ctx, "/api/call_request/", body, &res); err != nil {
+		return res, fmt.Errorf("create call request: %w", err)
+	}
+	return res, nil
+}
+
+func (in CallRequestInput) validate() error {
+	phone := strings.TrimPrefix(in.Phone, "+")
+	if len(phone) == 0 {
+		return &ValidationError{Field: "phone", Message: "phone is required"}
+	}
+	for _, r := range phone {
+		if r < '0' || r > '9' {
+			return &ValidationError{Field: "phone", Message: fmt.Sprintf("phone %q has non digit characters", in.Phone)}
+		}
+	}
+	if in.CampaignID < 0 {
+		return &ValidationError{Field: "campaign id", Message: "campaign id is negative"}
+	}
+	return nil
+}
+
+type callRequestBody struct {
+	Phone         string `json:"contact_phone_number"`
+	CampaignID    int64  `json:"ac_id,omitempty"`
+	ScheduledTime string `json:"scheduled_time,omitempty"`
+}
@@ -0,0 +1,74 @@
+package comagic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nk2ge5k/go-api-comagic/comagictest"
+)
+
+func TestCreateCallRequest(t *testing.T) {
+	var got map[string]interface{}
+	srv := comagictest.NewServer(t, comagictest.WithHandler("/api/call_request/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("got %s request, want POST", r.Method)
+		}
+		got = map[string]interface{}{}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("invalid request body: %v", err)
+		}
+		comagictest.Respond(w, map[string]interface{}{"call_request_id": 42, "status": "scheduled"})
+	})))
+	c := NewClient(New("login", "password", WithBaseURL(srv.URL())))
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, DefaultLocation)
+	res, err := c.CreateCallRequest(context.Background(), CallRequestInput{Phone: "+74950000001", CampaignID: 7, ScheduledAt: at})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.ID != 42 || res.Status != "scheduled" {
+		t.Errorf("got result %+v, want request 42 scheduled", res)
+	}
+	if got["contact_phone_number"] != "+74950000001" || got["ac_id"] != float64(7) || got["scheduled_time"] != "2024-01-02 03:04:05" {
+		t.Errorf("got request body %v", got)
+	}
+
+	if _, err := c.CreateCallRequest(context.Background(), CallRequestInput{Phone: "74950000001"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 1 {
+		t.Errorf("got request body %v, want optional fields omitted", got)
+	}
+}
+
+func TestCreateCallRequestErrors(t *testing.T) {
+	var requests atomic.Int32
+	srv := comagictest.NewServer(t, comagictest.WithHandler("/api/call_request/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		comagictest.RespondError(w, "invalid_phone", "phone is not allowed")
+	})))
+	c := NewClient(New("login", "password", WithBaseURL(srv.URL())))
+	ctx := context.Background()
+	for _, in := range []CallRequestInput{{}, {Phone: "8 (495) 000"}, {Phone: "74950000001", CampaignID: -1}} {
+		_, err := c.CreateCallRequest(ctx, in)
+		ve := &ValidationError{}
+		if !errors.As(err, &ve) {
+			t.Errorf("input %+v: got error %v, want ValidationError", in, err)
+		}
+	}
+	if n := requests.Load(); n != 0 {
+		t.Fatalf("got %d requests of invalid input", n)
+	}
+	_, err := c.CreateCallRequest(ctx, CallRequestInput{Phone: "74950000001"})
+	ae := &APIError{}
+	if !errors.As(err, &ae) || ae.Code != "invalid_phone" {
+		t.Fatalf("got error %v, want invalid_phone APIError", err)
+	}
+	if errors.As(err, new(*ValidationError)) {
+		t.Errorf("API rejection reported as validation error: %v", err)
+	}
+}
@@ -0,0 +1,379 @@
+package comagic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DateTimeLayout is a layout of date and time values used by API,
+// values are in account time zone
+const DateTimeLayout = "2006-01-02 15:04:05"
+
+// DateTime is a time decoded from API date and time representation.
+// Time is in account time zone: DefaultLocation or the one set by
+// WithLocation for values returned by Client
+type DateTime struct {
+	time.Time
+}
+
+// UnmarshalJSON implements json.Unmarshaler interface,
+// time is decoded in DefaultLocation
+func (d *DateTime) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	if len(s) == 0 || s == "null" {
+		d.Time = time.Time{}
+		return nil
+	}
+	t, err := time.ParseInLocation(DateTimeLayout, s, DefaultLocation)
+	if err != nil {
+		return err
+	}
+	d.Time = t
+	return nil
+}
+
+// CallsReportRequest is a calls report request parameters
+type CallsReportRequest struct {
+	DateFrom time.Time
+	DateTill time.Time
+	// Offset and Limit used for pagination, zero limit means API default
+	Offset int
+	Limit  int
+}
+
+// CallsReportResponse is a calls report
+type CallsReportResponse struct {
+	Calls []Call
+}
+
+// Call is a single call record of calls report
+type Call struct {
+	ID              int64         `json:"id"`
+	CallDate        DateTime      `json:"call_date"`
+	CommunicationID int64         `json:"communication_id"`
+	VisitorID       int64         `json:"visitor_id"`
+	SiteID          int64         `json:"site_id"`
+	CampaignID      int64         `json:"ac_id"`
+	CallerNumber    string        `json:"numa"`
+	VirtualNumber   string        `json:"numb"`
+	Direction       CallDirection `json:"direction"`
+	Status          CallStatus    `json:"status"`
+	WaitTime        Duration      `json:"wait_time"`
+	Duration        Duration      `json:"duration"`
+	FileLink        string        `json:"file_link"`
+	Tags            []CallTag     `json:"tags"`
+	// Extra holds response fields unknown to the package
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler interface
+func (c *Call) UnmarshalJSON(b []byte) error {
+	type plain Call
+	if err := json.Unmarshal(b, (*plain)(c)); err != nil {
+		return err
+	}
+	extra, err := unknownFields(b, c)
+	c.Extra = extra
+	return err
+}
+
+// CallDirection is a direction of the call
+type CallDirection string
+
+// Known call directions
+const (
+	CallIn  CallDirection = "in"
+	CallOut CallDirection = "out"
+)
+
+// UnmarshalJSON implements json.Unmarshaler interface,
+// unknown directions are kept as is
+func (d *CallDirection) UnmarshalJSON(b []byte) error {
+	s, err := enumString(b)
+	if err != nil {
+		return fmt.Errorf("call direction: %w", err)
+	}
+	*d = CallDirection(s)
+	if v := CallDirection(strings.ToLower(s)); v.Known() {
+		*d = v
+	}
+	return nil
+}
+
+// Known returns true if direction is one of known directions
+func (d CallDirection) Known() bool {
+	return d == CallIn || d == CallOut
+}
+
+// IsIncoming returns true for incoming call
+func (d CallDirection) IsIncoming() bool {
+	return d == CallIn
+}
+
+// IsOutgoing returns true for outgoing call
+func (d CallDirection) IsOutgoing() bool {
+	return d == CallOut
+}
+
+// CallStatus is a status of the call
+type CallStatus string
+
+// Known call statuses
+const (
+	CallAnswered CallStatus = "answered"
+	CallMissed   CallStatus = "missed"
+)
+
+// UnmarshalJSON implements json.Unmarshaler interface,
+// unknown statuses are kept as is
+func (s *CallStatus) UnmarshalJSON(b []byte) error {
+	str, err := enumString(b)
+	if err != nil {
+		return fmt.Errorf("call status: %w", err)
+	}
+	*s = CallStatus(str)
+	if v := CallStatus(strings.ToLower(str)); v.Known() {
+		*s = v
+	}
+	return nil
+}
+
+// Known returns true if status is one of known statuses
+func (s CallStatus) Known() bool {
+	return s == CallAnswered || s == CallMissed
+}
+
+// IsAnswered returns true if call was answered
+func (s CallStatus) IsAnswered() bool {
+	return s == CallAnswered
+}
+
+// IsMissed returns true if call was missed
+func (s CallStatus) IsMissed() bool {
+	return s == CallMissed
+}
+
+// enumString decodes enum value which is either string or null
+func enumString(b []byte) (string, error) {
+	if string(b) == "null" {
+		return "", nil
+	}
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(s), nil
+}
+
+// CallTag is a tag attached to the call
+type CallTag struct {
+	ID   int64  `json:"tag_id"`
+	Name string `json:"tag_name"`
+}
+
+// CallsReport returns calls made in requested period
+func (c *Client) CallsReport(ctx context.Context, req CallsReportRequest) (CallsReportResponse, error) {
+	resp := CallsReportResponse{}
+	if err := c.validatePeriod(req.DateFrom, req.DateTill); err != nil {
+		return resp, fmt.Errorf("calls report: %w", err)
+	}
+	calls, err := getPaged[Call](ctx, c, "/api/calls_report/", func(offset, limit int) url.Values {
+		return reportQuery(c.loc, req.DateFrom, req.DateTill, offset, limit)
+	}, req.Offset, req.Limit)
+	if err != nil {
+		return resp, fmt.Errorf("calls report: %w", err)
+	}
+	resp.Calls = calls
+	return resp, nil
+}
+
+// CallsReportStream calls fn for every call made in requested period decoding
+// calls one by one, so whole report is never held in memory.
+// Error returned by fn stops decoding and is returned as is
+func (c *Client) CallsReportStream(ctx context.Context, req CallsReportRequest, fn func(Call) error) error {
+	if err := c.validatePeriod(req.DateFrom, req.DateTill); err != nil {
+		return fmt.Errorf("calls report: %w", err)
+	}
+	err := c.getStream(ctx, "/api/calls_report/", req.query(c.loc), func(dec JSONDecoder) error {
+		call := Call{}
+		if err := dec.Decode(&call); err != nil {
+			return fmt.Errorf("could not decode call: %v", err)
+		}
+		c.localize(&call)
+		if err := fn(call); err != nil {
+			return callbackError{err}
+		}
+		return nil
+	})
+	if cerr, ok := err.(callbackError); ok {
+		return cerr.err
+	}
+	if err != nil {
+		return fmt.Errorf("calls report: %w", err)
+	}
+	return nil
+}
+
+// CallsReportCSV writes calls made in requested period into w in CSV
+// representation exactly as returned by API, without decoding
+func (c *Client) CallsReportCSV(ctx context.Context, req CallsReportRequest, w io.Writer) error {
+	if err := c.validatePeriod(req.DateFrom, req.DateTill); err != nil {
+		return fmt.Errorf("calls report: %w", err)
+	}
+	if _, _, err := c.getExport(ctx, "/api/calls_report/", req.query(c.loc), "text/csv", w); err != nil {
+		return fmt.Errorf("calls report: %w", err)
+	}
+	return nil
+}
+
+// CallsReportRange fetches calls made in period from till day by day in account
+// time zone, up to parallelism days at once, and calls fn for every day in order
+// with the day midnight and all its calls fetched page by page of MaxPageSize.
+// Configured rate limiter is honored as for any other request and maximum
+// report period is checked for request of every day, not the whole period.
+// The first error either of request or of fn stops fetching and is returned,
+// error of fn is returned as is
+func (c *Client) CallsReportRange(ctx context.Context, from, till time.Time, parallelism int, fn func(day time.Time, calls []Call) error) error {
+	if till.Before(from) {
+		return fmt.Errorf("calls report: %w", &ValidationError{Field: "period", Message: "date from is after date till"})
+	}
+	if parallelism <= 0 {
+		parallelism = 1
+	}
+	days := splitDays(from, till, c.loc)
+	for _, d := range days {
+		if err := c.validatePeriod(d.from, d.till); err != nil {
+			return fmt.Errorf("calls report: %w", err)
+		}
+	}
+	results := make([]chan dayCalls, len(days))
+	for i := range results {
+		results[i] = make(chan dayCalls, 1)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	wg := sync.WaitGroup{}
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+	// slot is taken before fetching a day and released after fn is called
+	// for it, so at most parallelism days are fetched or held in memory.
+	// Slot is taken before day is picked, so the earliest pending day
+	// always has a slot and the loop below never waits forever
+	slots := make(chan struct{}, parallelism)
+	// failed receives the first error, requests of other days
+	// fail with context error once it cancels them
+	failed := make(chan error, 1)
+	next := atomic.Int64{}
+	for w := 0; w < parallelism && w < len(days); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case slots <- struct{}{}:
+				}
+				i := int(next.Add(1) - 1)
+				if i >= len(days) || ctx.Err() != nil {
+					return
+				}
+				calls, err := c.fetchDay(ctx, days[i])
+				if err != nil && ctx.Err() == nil {
+					select {
+					case failed <- err:
+					default:
+					}
+					cancel()
+				}
+				results[i] <- dayCalls{calls: calls, err: err}
+			}
+		}()
+	}
+
+	for i, d := range days {
+		var res dayCalls
+		select {
+		case res = <-results[i]:
+		case <-ctx.Done():
+			res.err = fmt.Errorf("calls report: %w", ctx.Err())
+		}
+		if res.err != nil {
+			select {
+			case err := <-failed:
+				return err
+			default:
+				return res.err
+			}
+		}
+		if err := fn(d.day, res.calls); err != nil {
+			return err
+		}
+		<-slots
+	}
+	return nil
+}
+
+// fetchDay fetches all calls made in given period page by page
+func (c *Client) fetchDay(ctx context.Context, p period) ([]Call, error) {
+	fetch := pager[Call](c, "/api/calls_report/", func(req PageRequest) url.Values {
+		return reportQuery(c.loc, p.from, p.till, req.Offset, req.Limit)
+	}, false)
+	calls, err := NewPageCursor(ctx, MaxPageSize, fetch).All()
+	if err != nil {
+		return nil, fmt.Errorf("calls report: %w", err)
+	}
+	return calls, nil
+}
+
+// dayCalls is a result of fetching calls of a single day
+type dayCalls struct {
+	calls []Call
+	err   error
+}
+
+// period is a part of report period within a single day
+type period struct {
+	day        time.Time
+	from, till time.Time
+}
+
+// splitDays splits period into days in given location, the last second of a day
+// is its till, so calls made exactly at midnight are not reported twice
+func splitDays(from, till time.Time, loc *time.Location) []period {
+	var days []period
+	from, till = from.In(loc), till.In(loc)
+	for day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, loc); !day.After(till); day = day.AddDate(0, 0, 1) {
+		p := period{day: day, from: day, till: day.AddDate(0, 0, 1).Add(-time.Second)}
+		if p.from.Before(from) {
+			p.from = from
+		}
+		if p.till.After(till) {
+			p.till = till
+		}
+		days = append(days, p)
+	}
+	return days
+}
+
+// callbackError marks error returned by user callback
+type callbackError struct {
+	err error
+}
+
+func (e callbackError) Error() string {
+	return e.err.Error()
+}
+
+func (r CallsReportRequest) query(loc *time.Location) url.Values {
+	return reportQuery(loc, r.DateFrom, r.DateTill, r.Offset, r.Limit)
+}
@@ -0,0 +1,346 @@
+package comagic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nk2ge5k/go-api-comagic/comagictest"
+)
+
+func TestCallsReportRangeParallelism(t *testing.T) {
+	const parallelism = 3
+	var inFlight, maxInFlight atomic.Int32
+	srv := comagictest.NewServer(t, comagictest.WithHandler("/api/calls_report/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		comagictest.Respond(w, []map[string]interface{}{{"id": 1, "call_date": r.URL.Query().Get("date_from")}})
+	})))
+	c := NewClient(New("login", "password", WithBaseURL(srv.URL())))
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, DefaultLocation)
+	till := from.AddDate(0, 0, 10).Add(-time.Second)
+	var days []time.Time
+	err := c.CallsReportRange(context.Background(), from, till, parallelism, func(day time.Time, calls []Call) error {
+		if len(calls) != 1 {
+			t.Errorf("day %s: got %d calls, want 1", day, len(calls))
+		}
+		days = append(days, day)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(days) != 10 {
+		t.Fatalf("got %d days, want 10", len(days))
+	}
+	for i, day := range days {
+		if want := from.AddDate(0, 0, i); !day.Equal(want) {
+			t.Errorf("day %d is %s, want %s", i, day, want)
+		}
+	}
+	if n := maxInFlight.Load(); n > parallelism {
+		t.Errorf("got %d concurrent requests, want at most %d", n, parallelism)
+	}
+}
+
+func TestCallsReportRangeErrorCancels(t *testing.T) {
+	const parallelism = 3
+	var requests atomic.Int32
+	failDay := time.Date(2024, 1, 2, 0, 0, 0, 0, DefaultLocation).Format(DateTimeLayout)
+	srv := comagictest.NewServer(t, comagictest.WithHandler("/api/calls_report/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.URL.Query().Get("date_from") == failDay {
+			comagictest.RespondError(w, "invalid_parameter", "broken day")
+			return
+		}
+		comagictest.Respond(w, []map[string]interface{}{})
+	})))
+	c := NewClient(New("login", "password", WithBaseURL(srv.URL())))
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, DefaultLocation)
+	till := from.AddDate(0, 0, 60)
+	err := c.CallsReportRange(context.Background(), from, till, parallelism, func(time.Time, []Call) error {
+		time.Sleep(5 * time.Millisecond)
+		return nil
+	})
+	ae := &APIError{}
+	if !errors.As(err, &ae) || ae.Message != "broken day" {
+		t.Fatalf("got error %v, want API error of failed day", err)
+	}
+	if n := requests.Load(); n > 2+parallelism {
+		t.Errorf("got %d requests after failure, want at most %d", n, 2+parallelism)
+	}
+}
+
+func TestCallsReportRangeMaxPeriod(t *testing.T) {
+	var requests atomic.Int32
+	srv := comagictest.NewServer(t, comagictest.WithHandler("/api/calls_report/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		comagictest.Respond(w, []map[string]interface{}{})
+	})))
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, DefaultLocation)
+	till := from.AddDate(0, 0, 30).Add(-time.Second)
+	fn := func(time.Time, []Call) error { return nil }
+
+	// period of every day is checked, so long period is fetched day by day
+	c := NewClient(New("login", "password", WithBaseURL(srv.URL())), WithMaxReportPeriod(24*time.Hour))
+	if err := c.CallsReportRange(context.Background(), from, till, 2, fn); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := requests.Load(); n != 30 {
+		t.Fatalf("got %d requests, want 30", n)
+	}
+
+	requests.Store(0)
+	c = NewClient(New("login", "password", WithBaseURL(srv.URL())), WithMaxReportPeriod(12*time.Hour))
+	err := c.CallsReportRange(context.Background(), from, till, 2, fn)
+	ve := &ValidationError{}
+	if !errors.As(err, &ve) || ve.Field != "period" {
+		t.Fatalf("got error %v, want period ValidationError", err)
+	}
+	if n := requests.Load(); n != 0 {
+		t.Errorf("got %d requests, want none sent", n)
+	}
+}
+
+func TestCallsReportRangeReadAhead(t *testing.T) {
+	const parallelism = 2
+	var requests atomic.Int32
+	srv := comagictest.NewServer(t, comagictest.WithHandler("/api/calls_report/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		comagictest.Respond(w, []map[string]interface{}{})
+	})))
+	c := NewClient(New("login", "password", WithBaseURL(srv.URL())))
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, DefaultLocation)
+	fnErr := errors.New("stop")
+	var once sync.Once
+	err := c.CallsReportRange(context.Background(), from, from.AddDate(0, 0, 30), parallelism, func(time.Time, []Call) error {
+		// let workers fetch as much as they are allowed to
+		time.Sleep(50 * time.Millisecond)
+		var err error
+		once.Do(func() { err = fnErr })
+		return err
+	})
+	if !errors.Is(err, fnErr) {
+		t.Fatalf("got error %v, want error of fn", err)
+	}
+	if n := requests.Load(); n > parallelism {
+		t.Errorf("got %d requests while the first day is processed, want at most %d", n, parallelism)
+	}
+}
+
+func TestCallsReportRangePages(t *testing.T) {
+	busyDay := time.Date(2024, 1, 2, 0, 0, 0, 0, DefaultLocation).Format(DateTimeLayout)
+	srv := comagictest.NewServer(t, comagictest.WithHandler("/api/calls_report/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("limit") != strconv.Itoa(MaxPageSize) {
+			t.Errorf("got limit %q, want %d", q.Get("limit"), MaxPageSize)
+		}
+		// busy day has a full page and one call more
+		n := 1
+		if q.Get("date_from") == busyDay && len(q.Get("offset")) == 0 {
+			n = MaxPageSize
+		}
+		calls := make([]map[string]interface{}, n)
+		for i := range calls {
+			calls[i] = map[string]interface{}{"id": i + 1}
+		}
+		comagictest.Respond(w, calls)
+	})))
+	c := NewClient(New("login", "password", WithBaseURL(srv.URL())))
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, DefaultLocation)
+	got := map[string]int{}
+	err := c.CallsReportRange(context.Background(), from, from.AddDate(0, 0, 3).Add(-time.Second), 2, func(day time.Time, calls []Call) error {
+		got[day.Format(DateTimeLayout)] = len(calls)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got[busyDay] != MaxPageSize+1 || len(got) != 3 {
+		t.Errorf("got calls per day %v, want %d calls of %s", got, MaxPageSize+1, busyDay)
+	}
+}
+
+func TestCallsReport(t *testing.T) {
+	var query url.Values
+	srv := comagictest.NewServer(t, comagictest.WithHandler("/api/calls_report/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		comagictest.Respond(w, []map[string]interface{}{{
+			"id": 1, "call_date": "2024-01-01 10:00:00", "ac_id": 7, "numa": "74950000001", "numb": "74950000002",
+			"direction": "in", "status": "answered", "duration": 65, "wait_time": 5,
+			"tags":        []map[string]interface{}{{"tag_id": 3, "tag_name": "lead"}},
+			"new_feature": true,
+		}})
+	})))
+	c := NewClient(New("login", "password", WithBaseURL(srv.URL())))
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, DefaultLocation)
+	report, err := c.CallsReport(context.Background(), CallsReportRequest{DateFrom: from, DateTill: from.Add(24 * time.Hour), Offset: 5, Limit: 10})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if query.Get("date_from") != "2024-01-01 00:00:00" || query.Get("date_till") != "2024-01-02 00:00:00" {
+		t.Errorf("got period %q - %q, want 2024-01-01 00:00:00 - 2024-01-02 00:00:00", query.Get("date_from"), query.Get("date_till"))
+	}
+	if query.Get("offset") != "5" || query.Get("limit") != "10" {
+		t.Errorf("got offset %q and limit %q, want 5 and 10", query.Get("offset"), query.Get("limit"))
+	}
+	if len(report.Calls) != 1 {
+		t.Fatalf("got %d calls, want 1", len(report.Calls))
+	}
+	call := report.Calls[0]
+	if call.ID != 1 || call.CampaignID != 7 || call.CallerNumber != "74950000001" || call.VirtualNumber != "74950000002" {
+		t.Errorf("got call %+v", call)
+	}
+	if want := time.Date(2024, 1, 1, 10, 0, 0, 0, DefaultLocation); !call.CallDate.Equal(want) {
+		t.Errorf("got call date %s, want %s", call.CallDate, want)
+	}
+	if !call.Direction.IsIncoming() || !call.Status.IsAnswered() {
+		t.Errorf("got direction %q and status %q, want incoming answered call", call.Direction, call.Status)
+	}
+	if time.Duration(call.Duration) != 65*time.Second || time.Duration(call.WaitTime) != 5*time.Second {
+		t.Errorf("got duration %s and wait time %s, want 1m5s and 5s", time.Duration(call.Duration), time.Duration(call.WaitTime))
+	}
+	if len(call.Tags) != 1 || call.Tags[0] != (CallTag{ID: 3, Name: "lead"}) {
+		t.Errorf("got tags %+v, want lead tag", call.Tags)
+	}
+	if _, ok := call.Extra["new_feature"]; !ok {
+		t.Errorf("unknown field is not kept, got extra %v", call.Extra)
+	}
+}
+
+func TestCallsReportAPIError(t *testing.T) {
+	srv := comagictest.NewServer(t, comagictest.WithHandler("/api/calls_report/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		comagictest.RespondError(w, "invalid_period", "date_till is before date_from")
+	})))
+	c := NewClient(New("login", "password", WithBaseURL(srv.URL())))
+	from := time.Now().Add(-time.Hour)
+	_, err := c.CallsReport(context.Background(), CallsReportRequest{DateFrom: from, DateTill: from.Add(time.Minute)})
+	ae := &APIError{}
+	if !errors.As(err, &ae) {
+		t.Fatalf("got error %v, want APIError", err)
+	}
+	if ae.Code != "invalid_period" || ae.Message != "date_till is before date_from" {
+		t.Errorf("got API error %+v", ae)
+	}
+}
+
+func TestCallsReportCSV(t *testing.T) {
+	csv := "id;start_time;contact_phone_number\r\n1;2024-01-02 03:04:05;\"7 (495) 000-00-01\"\r\n" + strings.Repeat("2;2024-01-02 04:00:00;74950000002\r\n", 1000)
+	srv := comagictest.NewServer(t, comagictest.WithHandler("/api/calls_report/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if accept := r.Header.Get("Accept"); accept != "text/csv" {
+			t.Errorf("got Accept %q, want text/csv", accept)
+		}
+		w.Header().Set("Content-Type", "text/csv")
+		w.Write([]byte(csv))
+	})))
+	c := NewClient(New("login", "password", WithBaseURL(srv.URL())))
+	from := time.Now().Add(-time.Hour)
+	req := CallsReportRequest{DateFrom: from, DateTill: from.Add(time.Minute)}
+	for i := 0; i < 2; i++ {
+		buf := &bytes.Buffer{}
+		if err := c.CallsReportCSV(context.Background(), req, buf); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if buf.String() != csv {
+			t.Fatalf("got %d bytes of CSV, want %d bytes unchanged", buf.Len(), len(csv))
+		}
+		// export is replayed with renewed session
+		srv.ExpireSession()
+	}
+	if n := srv.Logins(); n != 2 {
+		t.Errorf("got %d logins, want expired session renewed", n)
+	}
+}
+
+func TestCallsReportCSVAPIError(t *testing.T) {
+	srv := comagictest.NewServer(t, comagictest.WithHandler("/api/calls_report/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		comagictest.RespondError(w, "invalid_period", "date_till is before date_from")
+	})))
+	c := NewClient(New("login", "password", WithBaseURL(srv.URL())))
+	from := time.Now().Add(-time.Hour)
+	buf := &bytes.Buffer{}
+	err := c.CallsReportCSV(context.Background(), CallsReportRequest{DateFrom: from, DateTill: from.Add(time.Minute)}, buf)
+	ae := &APIError{}
+	if !errors.As(err, &ae) || ae.Code != "invalid_period" {
+		t.Fatalf("got error %v, want invalid_period APIError", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("got %q written, want error envelope not copied", buf)
+	}
+}
+
+func TestCallEnums(t *testing.T) {
+	tests := []struct {
+		json      string
+		direction CallDirection
+		status    CallStatus
+		known     bool
+	}{
+		{`{"direction":"in","status":"answered"}`, CallIn, CallAnswered, true},
+		{`{"direction":"OUT","status":"Missed"}`, CallOut, CallMissed, true},
+		{`{"direction":"transit","status":"voicemail"}`, "transit", "voicemail", false},
+		{`{"direction":null,"status":null}`, "", "", false},
+	}
+	for _, tt := range tests {
+		var call Call
+		if err := json.Unmarshal([]byte(tt.json), &call); err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.json, err)
+		}
+		if call.Direction != tt.direction || call.Status != tt.status {
+			t.Errorf("%s: got direction %q and status %q, want %q and %q", tt.json, call.Direction, call.Status, tt.direction, tt.status)
+		}
+		if call.Direction.Known() != tt.known || call.Status.Known() != tt.known {
+			t.Errorf("%s: got known direction %v and status %v, want %v", tt.json, call.Direction.Known(), call.Status.Known(), tt.known)
+		}
+	}
+	if !CallIn.IsIncoming() || CallIn.IsOutgoing() || !CallOut.IsOutgoing() {
+		t.Error("got wrong direction predicates")
+	}
+	if !CallAnswered.IsAnswered() || CallAnswered.IsMissed() || !CallMissed.IsMissed() || CallStatus("voicemail").IsAnswered() {
+		t.Error("got wrong status predicates")
+	}
+	var d CallDirection
+	if err := json.Unmarshal([]byte(`1`), &d); err == nil {
+		t.Errorf("got direction %q of number, want error", d)
+	}
+}
+
+func TestCallsReportRangeDeadline(t *testing.T) {
+	var requests atomic.Int32
+	l := &intervalLimiter{interval: 100 * time.Millisecond}
+	c := NewClient(New("", "", WithToken("token"), WithRateLimiter(l), WithTransport(sequenceStub(&requests, nil, http.StatusOK))))
+	ctx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+	defer cancel()
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, DefaultLocation)
+	start := time.Now()
+	err := c.CallsReportRange(ctx, from, from.AddDate(0, 0, 30), 3, func(time.Time, []Call) error { return nil })
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got error %v, want context deadline", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("bulk fetch returned after %s, want it to stop at deadline", d)
+	}
+	if n := requests.Load(); n >= 30 {
+		t.Errorf("got %d requests, want fetching stopped at deadline", n)
+	}
+}
@@ -0,0 +1,146 @@
+package comagic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Grouping is a period campaign statistics is grouped by
+type Grouping string
+
+// Supported groupings
+const (
+	GroupByDay   Grouping = "day"
+	GroupByWeek  Grouping = "week"
+	GroupByMonth Grouping = "month"
+)
+
+// CampaignReportRequest is a campaign report request parameters
+type CampaignReportRequest struct {
+	DateFrom time.Time
+	DateTill time.Time
+	// CampaignIDs limits report to given campaigns, empty means all campaigns
+	CampaignIDs []int64
+	// GroupBy splits statistics by periods, empty means whole report period
+	GroupBy Grouping
+}
+
+// CampaignReportResponse is a campaign report
+type CampaignReportResponse struct {
+	Campaigns []CampaignStats
+}
+
+// CampaignStats is a statistics of advertising campaign
+type CampaignStats struct {
+	ID   int64  `json:"ac_id"`
+	Name string `json:"ac_name"`
+	// Date is a start of grouping period, zero if report is not grouped
+	Date          DateTime `json:"date"`
+	Visits        int      `json:"visits_count"`
+	Calls         int      `json:"calls_count"`
+	AnsweredCalls int      `json:"answered_calls_count"`
+	MissedCalls   int      `json:"missed_calls_count"`
+	Goals         int      `json:"goals_count"`
+	Conversion    float64  `json:"conversion"`
+	// Extra holds response fields unknown to the package
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler interface
+func (s *CampaignStats) UnmarshalJSON(b []byte) error {
+	type plain CampaignStats
+	if err := json.Unmarshal(b, (*plain)(s)); err != nil {
+		return err
+	}
+	extra, err := unknownFields(b, s)
+	s.Extra = extra
+	return err
+}
+
+// CampaignReport returns advertising campaigns statistics for requested period
+func (c *Client) CampaignReport(ctx context.Context, req CampaignReportRequest) (CampaignReportResponse, error) {
+	if err := c.validatePeriod(req.DateFrom, req.DateTill); err != nil {
+		return CampaignReportResponse{}, fmt.Errorf("campaign report: %w", err)
+	}
+	v := reportQuery(c.loc, req.DateFrom, req.DateTill, 0, 0)
+	if len(req.CampaignIDs) > 0 {
+		ids := make([]string, len(req.CampaignIDs))
+		for i, id := range req.CampaignIDs {
+			ids[i] = strconv.FormatInt(id, 10)
+		}
+		v.Set("ac_id", strings.Join(ids, ","))
+	}
+	if len(req.GroupBy) > 0 {
+		v.Set("group_by", string(req.GroupBy))
+	}
+	resp := CampaignReportResponse{Campaigns: []CampaignStats{}}
+	if err := c.GetJSON(ctx, "/api/campaigns/", v, &resp.Campaigns); err != nil {
+		return resp, fmt.Errorf("campaign report: %w", err)
+	}
+	return resp, nil
+}
+
+// ListCampaignsRequest is a campaign list request parameters
+type ListCampaignsRequest struct {
+	// Offset and Limit used for pagination, zero limit means DefaultPageSize,
+	// limit above MaxPageSize is capped to it
+	Offset int
+	Limit  int
+}
+
+// CampaignPage is a page of campaign list
+type CampaignPage struct {
+	Campaigns []Campaign
+	// NextOffset is an offset of the next page
+	NextOffset int
+	// More is true if the next page could have campaigns
+	More bool
+}
+
+// Campaign is an advertising campaign configured in the account
+type Campaign struct {
+	ID           int64    `json:"id"`
+	Name         string   `json:"name"`
+	SiteID       int64    `json:"site_id"`
+	Status       string   `json:"status"`
+	CreationTime DateTime `json:"creation_time"`
+	// Extra holds response fields unknown to the package
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler interface
+func (cp *Campaign) UnmarshalJSON(b []byte) error {
+	type plain Campaign
+	if err := json.Unmarshal(b, (*plain)(cp)); err != nil {
+		return err
+	}
+	extra, err := unknownFields(b, cp)
+	cp.Extra = extra
+	return err
+}
+
+// ListCampaigns returns page of advertising campaigns configured in the account
+func (c *Client) ListCampaigns(ctx context.Context, req ListCampaignsRequest) (CampaignPage, error) {
+	limit := req.Limit
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+	v := url.Values{}
+	v.Set("offset", strconv.Itoa(req.Offset))
+	v.Set("limit", strconv.Itoa(limit))
+	page := CampaignPage{Campaigns: []Campaign{}}
+	if err := c.GetJSON(ctx, "/api/campaigns/", v, &page.Campaigns); err != nil {
+		return page, fmt.Errorf("list campaigns: %w", err)
+	}
+	page.NextOffset = req.Offset + len(page.Campaigns)
+	page.More = len(page.Campaigns) == limit
+	return page, nil
+}
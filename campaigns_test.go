@@ -0,0 +1,117 @@
+package comagic
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/nk2ge5k/go-api-comagic/comagictest"
+)
+
+func TestCampaignReport(t *testing.T) {
+	var query url.Values
+	srv := comagictest.NewServer(t, comagictest.WithHandler("/api/campaigns/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		comagictest.Respond(w, []map[string]interface{}{{
+			"ac_id": 7, "ac_name": "Search", "date": "2024-01-01 00:00:00",
+			"visits_count": 100, "calls_count": 10, "answered_calls_count": 8, "missed_calls_count": 2,
+			"goals_count": 3, "conversion": 0.1,
+		}})
+	})))
+	c := NewClient(New("login", "password", WithBaseURL(srv.URL())))
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, DefaultLocation)
+	report, err := c.CampaignReport(context.Background(), CampaignReportRequest{
+		DateFrom:    from,
+		DateTill:    from.AddDate(0, 0, 7),
+		CampaignIDs: []int64{7, 8},
+		GroupBy:     GroupByDay,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if query.Get("date_from") != "2024-01-01 00:00:00" || query.Get("date_till") != "2024-01-08 00:00:00" {
+		t.Errorf("got period %q - %q", query.Get("date_from"), query.Get("date_till"))
+	}
+	if query.Get("ac_id") != "7,8" || query.Get("group_by") != "day" {
+		t.Errorf("got campaigns %q grouped by %q, want 7,8 by day", query.Get("ac_id"), query.Get("group_by"))
+	}
+	if len(report.Campaigns) != 1 {
+		t.Fatalf("got %d campaigns, want 1", len(report.Campaigns))
+	}
+	s := report.Campaigns[0]
+	if s.ID != 7 || s.Name != "Search" || !s.Date.Equal(from) || s.Visits != 100 || s.Calls != 10 ||
+		s.AnsweredCalls != 8 || s.MissedCalls != 2 || s.Goals != 3 || s.Conversion != 0.1 {
+		t.Errorf("got campaign stats %+v", s)
+	}
+}
+
+func TestCampaignReportWithoutFilters(t *testing.T) {
+	var query url.Values
+	srv := comagictest.NewServer(t, comagictest.WithHandler("/api/campaigns/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		comagictest.Respond(w, nil)
+	})))
+	c := NewClient(New("login", "password", WithBaseURL(srv.URL())))
+	report, err := c.CampaignReport(context.Background(), CampaignReportRequest{DateFrom: time.Now().Add(-time.Hour), DateTill: time.Now()})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if query.Has("ac_id") || query.Has("group_by") {
+		t.Errorf("got query %v, want no campaign and grouping filters", query)
+	}
+	if report.Campaigns == nil {
+		t.Error("got nil campaigns of empty report")
+	}
+}
+
+func TestCampaignReportAPIError(t *testing.T) {
+	srv := comagictest.NewServer(t, comagictest.WithHandler("/api/campaigns/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		comagictest.RespondError(w, "invalid_grouping", "unknown grouping")
+	})))
+	c := NewClient(New("login", "password", WithBaseURL(srv.URL())))
+	_, err := c.CampaignReport(context.Background(), CampaignReportRequest{DateFrom: time.Now().Add(-time.Hour), DateTill: time.Now(), GroupBy: "year"})
+	ae := &APIError{}
+	if !errors.As(err, &ae) || ae.Code != "invalid_grouping" {
+		t.Fatalf("got error %v, want invalid_grouping APIError", err)
+	}
+}
+
+func TestListCampaigns(t *testing.T) {
+	srv := comagictest.NewServer(t, comagictest.WithHandler("/api/campaigns/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		page := []map[string]interface{}{}
+		for id := offset + 1; id <= 3 && id <= offset+limit; id++ {
+			page = append(page, map[string]interface{}{
+				"id": id, "name": "Campaign " + strconv.Itoa(id), "site_id": 7,
+				"status": "active", "creation_time": "2024-01-02 03:04:05",
+			})
+		}
+		comagictest.Respond(w, page)
+	})))
+	c := NewClient(New("login", "password", WithBaseURL(srv.URL())))
+	ctx := context.Background()
+	first, err := c.ListCampaigns(ctx, ListCampaignsRequest{Limit: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(first.Campaigns) != 2 || !first.More || first.NextOffset != 2 {
+		t.Fatalf("got first page %+v, want 2 campaigns and more", first)
+	}
+	cp := first.Campaigns[0]
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, DefaultLocation)
+	if cp.ID != 1 || cp.Name != "Campaign 1" || cp.SiteID != 7 || cp.Status != "active" || !cp.CreationTime.Equal(created) {
+		t.Errorf("got campaign %+v", cp)
+	}
+	second, err := c.ListCampaigns(ctx, ListCampaignsRequest{Offset: first.NextOffset, Limit: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(second.Campaigns) != 1 || second.Campaigns[0].ID != 3 || second.More || second.NextOffset != 3 {
+		t.Fatalf("got second page %+v, want the last campaign", second)
+	}
+}
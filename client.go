@@ -2,40 +2,236 @@ package comagic
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"mime/multipart"
+	"io"
+	"log/slog"
+	"math/rand"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 // SessionLifetime is a duration after session key will be invalid
 const SessionLifetime = time.Hour * 3
 
-var DefaultBaseURL = &url.URL{Scheme: "http", Host: "api.comagic.ru"}
+// Login request defaults
+const (
+	DefaultLoginPath     = "/api/login/"
+	DefaultLoginField    = "login"
+	DefaultPasswordField = "password"
+)
+
+// DefaultSessionSkew is a safety margin subtracted from session lifetime
+// to compensate clock skew and server side lifetime differences
+const DefaultSessionSkew = time.Minute
+
+// DefaultAuthRetries is a number of times request is replayed after
+// API reports that session key is expired
+const DefaultAuthRetries = 1
+
+// expiredSessionCode is an error code returned by API for invalidated session key
+const expiredSessionCode = "expired_session_key"
+
+// maxErrorSize is a maximum size of response body inspected for error payload
+const maxErrorSize = 64 << 10
+
+// Version is a version of the package
+const Version = "0.1.0"
+
+// DefaultUserAgent is a User-Agent header value sent when none is set
+const DefaultUserAgent = "go-api-comagic/" + Version
+
+// DefaultBaseURL is an API base URL used when none is set,
+// credentials are never sent in plain text by default
+var DefaultBaseURL = &url.URL{Scheme: "https", Host: "api.comagic.ru"}
 
 // WithTransport is an option function for setting custom http transport
 func WithTransport(rt http.RoundTripper) func(*Transport) {
 	return func(t *Transport) { t.Transport = rt }
 }
 
-// WithBaseURL is an option function for setting custom API base URL
+// WithHTTPClient is an option function for reusing existing http client:
+// its transport becomes underlying transport, so connection pool, proxy and
+// TLS settings are shared, and client returned by New copies its timeout,
+// cookie jar and redirect policy. Nil client means http.DefaultClient
+func WithHTTPClient(hc *http.Client) func(*Transport) {
+	return func(t *Transport) {
+		if hc == nil {
+			hc = http.DefaultClient
+		}
+		t.Transport = hc.Transport
+		t.baseClient = hc
+	}
+}
+
+// WithClientTimeout is an option function for setting Timeout of http client
+// returned by New. Timeout limits whole request including authorization,
+// retries and reading response body, request context deadline applies too
+// and the earlier of them wins
+func WithClientTimeout(d time.Duration) func(*Transport) {
+	return func(t *Transport) { t.clientTimeout = d }
+}
+
+// WithBaseURL is an option function for setting custom API base URL.
+// URL must have scheme and host and must not have query or fragment,
+// otherwise requests fail with invalid configuration error. Request paths
+// are appended to base URL path, so API could be reached through proxy
+// like https://proxy/comagic
 func WithBaseURL(u *url.URL) func(*Transport) {
 	return func(t *Transport) { t.BaseURL = u }
 }
 
+// WithAuthRetries is an option function for setting number of times request
+// is replayed with fresh session key after API reports that session is expired,
+// zero disables replay
+func WithAuthRetries(n int) func(*Transport) {
+	return func(t *Transport) { t.authRetries = n }
+}
+
+// WithSessionLifetime is an option function for setting custom session lifetime,
+// non positive duration means SessionLifetime
+func WithSessionLifetime(d time.Duration) func(*Transport) {
+	return func(t *Transport) { t.sessionLifetime = d }
+}
+
+// WithToken is an option function enabling access token authorization.
+// Token is sent as access_token query parameter and login request is never made.
+// Token could not be combined with login and password
+func WithToken(token string) func(*Transport) {
+	return func(t *Transport) { t.token = token }
+}
+
+// WithTokenInHeader is an option function for sending access token
+// in Authorization header as bearer token instead of query parameter
+func WithTokenInHeader() func(*Transport) {
+	return func(t *Transport) { t.tokenInHeader = true }
+}
+
+// WithSessionParam is an option function for setting name of query parameter
+// session key is sent in, default is session_key
+func WithSessionParam(name string) func(*Transport) {
+	return func(t *Transport) { t.sessionParam = name }
+}
+
+// WithSessionInHeader is an option function for sending session key
+// in given request header instead of query parameter
+func WithSessionInHeader(header string) func(*Transport) {
+	return func(t *Transport) { t.sessionHeader = header }
+}
+
+// WithUserAgent is an option function for setting User-Agent header value
+// sent with requests which do not have one
+func WithUserAgent(ua string) func(*Transport) {
+	return func(t *Transport) { t.userAgent = ua }
+}
+
+// WithAccept is an option function for setting Accept header value
+// sent with requests which do not have one, application/json by default
+func WithAccept(accept string) func(*Transport) {
+	return func(t *Transport) { t.accept = accept }
+}
+
+// WithDefaultQuery is an option function for setting query parameters added to
+// every request including authorization one, parameters set on request take precedence
+func WithDefaultQuery(values url.Values) func(*Transport) {
+	return func(t *Transport) {
+		if t.defaultQuery == nil {
+			t.defaultQuery = url.Values{}
+		}
+		for k, vs := range values {
+			t.defaultQuery[k] = append(t.defaultQuery[k], vs...)
+		}
+	}
+}
+
+// WithDefaultHeader is an option function for setting header added to
+// every request including authorization one, header set on request takes precedence
+func WithDefaultHeader(key, value string) func(*Transport) {
+	return func(t *Transport) {
+		if t.defaultHeader == nil {
+			t.defaultHeader = http.Header{}
+		}
+		t.defaultHeader.Add(key, value)
+	}
+}
+
+// WithTrailingSlash is an option function for switching trailing slash
+// added to request path, enabled by default since API requires it
+func WithTrailingSlash(enabled bool) func(*Transport) {
+	return func(t *Transport) { t.noTrailingSlash = !enabled }
+}
+
+// WithClock is an option function for setting function returning current time,
+// it is used for all session lifetime calculations
+func WithClock(now func() time.Time) func(*Transport) {
+	return func(t *Transport) { t.now = now }
+}
+
+// WithAuthTimeout is an option function for limiting duration of authorization
+// request, it is applied in addition to request context deadline.
+// On timeout authorization fails with ErrAuthTimeout
+func WithAuthTimeout(d time.Duration) func(*Transport) {
+	return func(t *Transport) { t.authTimeout = d }
+}
+
+// WithAuthJitter is an option function for delaying the first authorization
+// of the transport by random duration up to max, so many instances started
+// at once do not log in simultaneously. Zero disables delay
+func WithAuthJitter(max time.Duration) func(*Transport) {
+	return func(t *Transport) { t.authJitter = max }
+}
+
+// WithLoginPath is an option function for setting login endpoint path
+func WithLoginPath(path string) func(*Transport) {
+	return func(t *Transport) { t.loginURLPath = path }
+}
+
+// WithCredentialFields is an option function for setting names of
+// login request fields holding login and password
+func WithCredentialFields(loginField, passwordField string) func(*Transport) {
+	return func(t *Transport) {
+		t.loginField = loginField
+		t.passwordField = passwordField
+	}
+}
+
+// WithSessionSkew is an option function for setting safety margin
+// subtracted from session lifetime, zero means DefaultSessionSkew
+// and negative duration disables margin
+func WithSessionSkew(d time.Duration) func(*Transport) {
+	return func(t *Transport) { t.sessionSkew = d }
+}
+
 // New returns comagic API client
 func New(login, password string, opts ...func(*Transport)) *http.Client {
 	t := &Transport{}
+	t.authRetries = DefaultAuthRetries
 	for _, opt := range opts {
 		opt(t)
 	}
 	t.Login = login
 	t.Password = password
+	if t.refreshInterval > 0 {
+		t.startRefresh()
+	}
 
-	return &http.Client{Transport: t}
+	c := &http.Client{Transport: t}
+	if t.baseClient != nil {
+		c.Timeout = t.baseClient.Timeout
+		c.Jar = t.baseClient.Jar
+		c.CheckRedirect = t.baseClient.CheckRedirect
+	}
+	if t.clientTimeout > 0 {
+		c.Timeout = t.clientTimeout
+	}
+	return c
 }
 
 // Transport is http transport allowing to make requests comagic API a little bit easer
@@ -50,81 +246,691 @@ type Transport struct {
 	// Underlying transport
 	Transport http.RoundTripper
 
-	session struct {
+	// settings set by option functions, copied by Clone
+	config
+
+	// underlying transport built from the clone of default one
+	tuneOnce       sync.Once
+	tunedTransport *http.Transport
+
+	// background refresh state
+	closeOnce sync.Once
+	done      chan struct{}
+
+	// transports of credentials given with request context
+	tenants tenants
+
+	// number of successful authorizations and unix time in nanoseconds
+	// of the last one, read without lock by AuthCount and LastAuthTime
+	authCount atomic.Int64
+	lastAuth  atomic.Int64
+	// loginSeq counts finished logins, successful or not, request
+	// reads it before waiting for mu to learn whether login was made
+	// by another request in the meantime
+	loginSeq atomic.Int64
+
+	// mu guards fields below and serializes authorization requests.
+	// Session is never read or written without it: request path reads
+	// session key only through sessionKey, so concurrent re-authorization
+	// triggered by another request is not observed half way
+	mu            sessionLock
+	sessionLoaded bool
+	jittered      bool
+	// authResults are reported to metrics collector by unlock
+	authResults []bool
+	// loginErr is an error of the last login, nil if it succeeded
+	// or failed because its context was done
+	loginErr error
+	// lockedUntil is an end of account lock reported by the last login,
+	// logins are not attempted until then and lockErr is returned instead
+	lockedUntil time.Time
+	lockErr     *AuthError
+	session     struct {
 		key   string
 		start time.Time
+		// host session was issued by, nil means base URL
+		host *url.URL
 	}
 }
 
+// config holds transport settings set by option functions
+type config struct {
+	// baseClient is a client settings of which are copied by New
+	baseClient *http.Client
+	// clientTimeout overrides timeout of client returned by New if positive
+	clientTimeout time.Duration
+
+	// collector for request metrics, nil disables metrics
+	collector Metrics
+
+	// tracer for request spans, nil disables tracing
+	tracer Tracer
+
+	// hooks called around every request sent
+	requestHooks  []func(*http.Request) error
+	responseHooks []func(*http.Response) error
+
+	// dryRun disables sending requests
+	dryRun bool
+
+	// tap receives copy of every response body
+	tap func(*http.Request, []byte)
+
+	// logger for debug events, nil disables logging
+	logger *slog.Logger
+
+	// noCompression disables gzip compression of responses
+	noCompression bool
+
+	// noTrailingSlash disables trailing slash in request path
+	noTrailingSlash bool
+
+	// userAgent overrides DefaultUserAgent
+	userAgent string
+	// accept overrides application/json Accept header
+	accept string
+
+	// defaults merged into every request
+	defaultQuery  url.Values
+	defaultHeader http.Header
+
+	// sessionParam and sessionHeader override query parameter session key is sent in
+	sessionParam  string
+	sessionHeader string
+
+	// credentialProvider provides login and password instead of Transport fields
+	credentialProvider CredentialProvider
+
+	// access token used instead of login and password
+	token         string
+	tokenInHeader bool
+	tokenInBody   bool
+
+	// authRetries is a number of replays on expired session
+	authRetries int
+
+	// now overrides time.Now
+	now func() time.Time
+
+	// login request overrides
+	loginEncoding LoginEncoding
+	loginURLPath  string
+	loginField    string
+	passwordField string
+	// loginWriter wraps buffer multipart login body is written to,
+	// nil means the buffer itself
+	loginWriter func(io.Writer) io.Writer
+
+	// authTimeout limits authorization request if positive
+	authTimeout time.Duration
+	// authJitter delays the first authorization up to given duration
+	authJitter time.Duration
+
+	// sessionLifetime overrides SessionLifetime if positive
+	sessionLifetime time.Duration
+	// sessionSkew overrides DefaultSessionSkew if not zero
+	sessionSkew time.Duration
+
+	// settings applied to the clone of default transport
+	tlsConfig *tls.Config
+	pool      *connectionPool
+	proxy     *url.URL
+
+	// limiter throttles outgoing requests
+	limiter Limiter
+	// breaker stops sending requests to failing API
+	breaker CircuitBreaker
+
+	// maxResponseSize overrides DefaultMaxResponseSize if not zero
+	maxResponseSize int64
+
+	// retry policy for transient failures, nil disables retries
+	retry *RetryPolicy
+	// idempotency attaches idempotency keys to requests
+	idempotency bool
+	// correlationHeader sends correlation id in request header
+	correlationHeader bool
+
+	// store persists session between transports
+	store SessionStore
+	// strictAuthParam rejects requests with authorization query parameter
+	strictAuthParam bool
+
+	// refreshInterval enables background refresh if positive
+	refreshInterval time.Duration
+
+	// failover base URLs tried in order after BaseURL
+	failover []*url.URL
+
+	// lifecycle stops authorization and refresher when done, nil means never
+	lifecycle context.Context
+}
+
 // RoundTrip implements http.RoundrTripper interface allowing to
 // send authorization request to comagic API before any actual.
-// First request to API is not totaly concurent safe because it makes
-// underlying authorization request and populates sessionKey
-func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+// It is safe for concurrent use: while authorization request is in flight
+// other requests wait for it and reuse obtained session key.
+// If API reports that session key is expired, session is dropped and
+// request is replayed with a fresh one up to configured number of times.
+// Session is considered expired only on 401 Unauthorized response or on
+// error envelope with expired_session_key code, other statuses like 400,
+// 403 or 404 are returned to the caller as is.
+// Session key or access token set by the transport always replaces
+// the one already present in request query, see WithStrictAuthParam.
+// Request given to RoundTrip is never changed, request as it was sent,
+// including resolved URL with session key, is a Request of the response:
+// use RedactURL to log its URL
+func (t *Transport) RoundTrip(r *http.Request) (res *http.Response, err error) {
 	if r == nil {
 		return nil, fmt.Errorf("round trip: empty request")
 	}
-	if !t.sessionValid() {
-		if err := t.auth(); err != nil {
-			return nil, fmt.Errorf("round trip: could not authorize: %v", err)
+	if tt := t.tenant(r.Context()); tt != nil {
+		return tt.RoundTrip(r)
+	}
+	start := t.clock()
+	ctx, span := t.startSpan(t.withCorrelation(r.Context()), "comagic.request")
+	defer func() {
+		if err == nil && t.tap != nil {
+			if err = t.tapResponse(r, res); err != nil {
+				res = nil
+			}
+		}
+		endSpan(span, res, err)
+		t.observe(res, t.clock().Sub(start))
+	}()
+	// headers and URL are changed for sending, so caller's request
+	// is left intact and could be sent again
+	r = r.Clone(withAttempts(ctx))
+	if err := t.validate(); err != nil {
+		return nil, fmt.Errorf("round trip: invalid configuration: %v", err)
+	}
+	if err := t.alive(); err != nil {
+		return nil, fmt.Errorf("round trip: %w", err)
+	}
+	if t.replays() > 0 || t.retry != nil || len(t.failover) > 0 {
+		if err := rewindable(r); err != nil {
+			return nil, fmt.Errorf("round trip: could not buffer request body: %v", err)
+		}
+	}
+	if len(r.Header.Get("Accept")) == 0 {
+		r.Header.Set("Accept", t.acceptType())
+	}
+	// transport decompresses response itself only if it asked for compression,
+	// so compression requested by the caller is left to the caller
+	compressed := !t.noCompression && len(r.Header.Get("Accept-Encoding")) == 0
+	if compressed {
+		r.Header.Set("Accept-Encoding", "gzip")
+	}
+	if len(r.Header.Get("User-Agent")) == 0 {
+		r.Header.Set("User-Agent", t.ua())
+	}
+	if t.idempotency {
+		if err := setIdempotencyKey(r); err != nil {
+			return nil, fmt.Errorf("round trip: could not generate idempotency key: %v", err)
+		}
+	}
+	if err := t.checkAuthParam(r); err != nil {
+		return nil, fmt.Errorf("round trip: %w", err)
+	}
+	if t.correlationHeader {
+		setCorrelationHeader(r)
+	}
+	ref := r.URL
+	t.resolve(r, t.baseURL(), ref)
+	span.SetAttribute("comagic.path", r.URL.Path)
+	if t.dryRun {
+		return t.dryRoundTrip(r)
+	}
+	res, err = t.attempts(r, span, compressed)
+	if ref.IsAbs() {
+		return res, err
+	}
+	return t.failOver(r, ref, span, compressed, res, err)
+}
+
+// attempts sends request authorizing it with a session key,
+// request is replayed with a fresh session if API reports expired one
+func (t *Transport) attempts(r *http.Request, span Span, compressed bool) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		nextAttempt(r.Context())
+		key, err := t.sessionKey(r.Context())
+		if err != nil {
+			return nil, fmt.Errorf("round trip: could not authorize: %w", err)
+		}
+		t.authorize(r, key)
+
+		res, err := t.send(r)
+		if err != nil {
+			return nil, err
+		}
+		if compressed {
+			if err := decompress(res); err != nil {
+				res.Body.Close()
+				return nil, fmt.Errorf("round trip: could not decompress response: %v", err)
+			}
+		}
+		t.limitBody(r, res)
+		for _, hook := range t.responseHooks {
+			if err := hook(res); err != nil {
+				res.Body.Close()
+				return nil, fmt.Errorf("round trip: response hook: %w", err)
+			}
+		}
+		if attempt >= t.replays() {
+			return res, nil
+		}
+		expired, err := sessionExpired(res)
+		if err != nil {
+			res.Body.Close()
+			return nil, fmt.Errorf("round trip: could not read response: %w", err)
+		}
+		if !expired {
+			return res, nil
+		}
+		res.Body.Close()
+		span.SetAttribute("comagic.reauth", true)
+		if t.logger != nil {
+			t.log(r.Context(), slog.LevelDebug, "session expired",
+				slog.String("event", "session_expired"), slog.String("session", redact(key)),
+				slog.Int("attempt", attempt+1))
+		}
+		t.invalidate(key)
+		if err := rewind(r); err != nil {
+			return nil, fmt.Errorf("round trip: could not rewind request body: %w", err)
+		}
+	}
+}
+
+// resolve resolves request URL reference against base URL
+// and applies defaults to the request
+func (t *Transport) resolve(r *http.Request, base, ref *url.URL) {
+	if !ref.IsAbs() {
+		r.URL = joinPath(base, ref)
+	}
+	t.applyDefaults(r)
+	if !t.noTrailingSlash {
+		addTrailingSlash(r.URL)
+	}
+}
+
+// validate reports conflicting transport configuration
+func (t *Transport) validate() error {
+	if len(t.token) > 0 && (len(t.Login) > 0 || len(t.Password) > 0) {
+		return fmt.Errorf("token and login/password are mutually exclusive")
+	}
+	if t.credentialProvider != nil && len(t.token) > 0 {
+		return fmt.Errorf("token and credential provider are mutually exclusive")
+	}
+	if t.credentialProvider != nil && (len(t.Login) > 0 || len(t.Password) > 0) {
+		return fmt.Errorf("credential provider and login/password are mutually exclusive")
+	}
+	if t.Transport != nil && t.tuned() {
+		return fmt.Errorf("custom transport could not be combined with %s", strings.Join(t.tunedSettings(), ", "))
+	}
+	if t.BaseURL != nil {
+		if err := validateBaseURL(t.BaseURL); err != nil {
+			return err
+		}
+	}
+	for _, u := range t.failover {
+		if err := validateBaseURL(u); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateBaseURL checks that URL could be used as a base one
+func validateBaseURL(u *url.URL) error {
+	switch {
+	case u == nil:
+		return fmt.Errorf("base url is nil")
+	case len(u.Scheme) == 0:
+		return fmt.Errorf("base url %q has no scheme", u)
+	case len(u.Host) == 0:
+		return fmt.Errorf("base url %q has no host", u)
+	case len(u.RawQuery) > 0 || u.ForceQuery:
+		return fmt.Errorf("base url %q has query", u)
+	case len(u.Fragment) > 0:
+		return fmt.Errorf("base url %q has fragment", u)
+	}
+	return nil
+}
+
+// joinPath returns URL of relative reference inside of base URL path:
+// package paths are rooted, so with ResolveReference base URL
+// like https://proxy/comagic would lose its path
+func joinPath(base, ref *url.URL) *url.URL {
+	u := base.JoinPath(ref.EscapedPath())
+	if !strings.HasPrefix(u.Path, "/") {
+		// JoinPath keeps path relative if base one is empty
+		u.Path = "/" + u.Path
+		if len(u.RawPath) > 0 {
+			u.RawPath = "/" + u.RawPath
 		}
 	}
-	r.Header.Set("Accept", "application/json")
-	if !r.URL.IsAbs() {
-		r.URL = t.baseURL().ResolveReference(r.URL)
+	u.RawQuery = ref.RawQuery
+	u.Fragment = ref.Fragment
+	return u
+}
+
+// applyDefaults merges default query parameters and headers into request
+// keeping values already set on it
+func (t *Transport) applyDefaults(r *http.Request) {
+	if len(t.defaultQuery) > 0 {
+		v := r.URL.Query()
+		for k, vs := range t.defaultQuery {
+			if _, ok := v[k]; !ok {
+				v[k] = append([]string(nil), vs...)
+			}
+		}
+		r.URL.RawQuery = v.Encode()
+	}
+	for k, vs := range t.defaultHeader {
+		if _, ok := r.Header[k]; !ok {
+			r.Header[k] = append([]string(nil), vs...)
+		}
+	}
+}
+
+// replays returns number of times request could be replayed on expired session
+func (t *Transport) replays() int {
+	if len(t.token) > 0 {
+		// token could not be renewed
+		return 0
+	}
+	return t.authRetries
+}
+
+// authorize attaches session key or access token to request
+func (t *Transport) authorize(r *http.Request, key string) {
+	if len(t.token) > 0 && t.tokenInBody {
+		// token is already a part of request payload
+		return
+	}
+	if len(t.token) > 0 && t.tokenInHeader {
+		r.Header.Set("Authorization", "Bearer "+key)
+		return
+	}
+	if len(t.token) == 0 && len(t.sessionHeader) > 0 {
+		r.Header.Set(t.sessionHeader, key)
+		return
 	}
-	// add required session key
 	v := r.URL.Query()
-	v.Set("session_key", t.session.key)
+	v.Set(t.authParam(), key)
 	r.URL.RawQuery = v.Encode()
-	// add required trailing slash
-	if !strings.HasSuffix(r.URL.Path, "/") {
-		r.URL.Path += "/"
+}
+
+// authParam returns name of query parameter session key or access token
+// is sent in, empty if it is not sent in query
+func (t *Transport) authParam() string {
+	switch {
+	case len(t.token) == 0 && len(t.sessionHeader) > 0:
+		return ""
+	case len(t.token) == 0 && len(t.sessionParam) > 0:
+		return t.sessionParam
+	case len(t.token) == 0:
+		return "session_key"
+	case t.tokenInBody || t.tokenInHeader:
+		return ""
+	default:
+		return "access_token"
+	}
+}
+
+// sessionKey returns current session key authorizing first if session is not valid,
+// in token mode it returns access token
+func (t *Transport) sessionKey(ctx context.Context) (string, error) {
+	if len(t.token) > 0 {
+		return t.token, nil
+	}
+	seq := t.loginSeq.Load()
+	if err := t.mu.LockContext(ctx); err != nil {
+		return "", &AuthError{Err: err}
+	}
+	defer t.unlock()
+	if !t.sessionLoaded && t.store != nil {
+		t.sessionLoaded = true
+		if key, start, ok := t.store.Load(); ok {
+			t.session.key = key
+			t.session.start = start
+			if t.logger != nil {
+				t.log(ctx, slog.LevelDebug, "session loaded",
+					slog.String("event", "session_load"), slog.String("session", redact(key)))
+			}
+		}
+	}
+	if !t.sessionValid() {
+		// login made by another request while this one waited for the lock
+		// failed: its result is shared instead of repeating login
+		if t.loginErr != nil && t.loginSeq.Load() != seq {
+			return "", t.loginErr
+		}
+		if err := t.renew(ctx); err != nil {
+			return "", err
+		}
+	} else if t.logger != nil {
+		t.log(ctx, slog.LevelDebug, "session reused",
+			slog.String("event", "session_reuse"), slog.String("session", redact(t.session.key)))
+	}
+	return t.session.key, nil
+}
+
+// renew establishes new session and saves it into the store, t.mu must be held
+func (t *Transport) renew(ctx context.Context) error {
+	if now := t.clock(); now.Before(t.lockedUntil) {
+		err := *t.lockErr
+		err.RetryAfter = t.lockedUntil.Sub(now)
+		return &err
+	}
+	ctx, cancel := t.bind(ctx)
+	defer cancel()
+	if t.authJitter > 0 && !t.jittered {
+		if err := sleep(ctx, time.Duration(rand.Int63n(int64(t.authJitter))+1)); err != nil {
+			return &AuthError{Err: err}
+		}
+		t.jittered = true
+	}
+	ctx, span := t.startSpan(ctx, "comagic.auth")
+	start := t.clock()
+	err := t.timedAuth(ctx)
+	endSpan(span, nil, err)
+	t.authResults = append(t.authResults, err == nil)
+	t.loginErr = nil
+	if err != nil && ctx.Err() == nil {
+		t.loginErr = err
+	}
+	t.loginSeq.Add(1)
+	var ae *AuthError
+	if errors.As(err, &ae) && ae.Locked() && ae.RetryAfter > 0 {
+		t.lockedUntil, t.lockErr = t.clock().Add(ae.RetryAfter), ae
+	}
+	if t.logger != nil {
+		t.logAuth(ctx, t.clock().Sub(start), err)
+	}
+	if err != nil {
+		return err
+	}
+	if t.store != nil {
+		t.store.Save(t.session.key, t.session.start)
+	}
+	return nil
+}
+
+// invalidate drops session if it still has given key,
+// session renewed by another request in the meantime is kept
+func (t *Transport) invalidate(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.session.key == key {
+		t.session.key = ""
+		t.session.start = time.Time{}
 	}
-	return t.transport().RoundTrip(r)
 }
 
+// sessionValid reports whether session is established and not expired,
+// t.mu must be held
 func (t *Transport) sessionValid() bool {
-	return len(t.session.key) > 0 && time.Since(t.session.start) < SessionLifetime
+	if len(t.token) > 0 {
+		return true
+	}
+	return len(t.session.key) > 0 && t.clock().Sub(t.session.start) < t.validFor()
 }
 
-func (t *Transport) auth() error {
-	reqURL := t.baseURL().ResolveReference(&url.URL{Path: "/api/login/"})
-	buf := bytes.NewBuffer(nil)
+// timedAuth makes authorization request limited by auth timeout if it is set
+func (t *Transport) timedAuth(ctx context.Context) error {
+	if t.authTimeout <= 0 {
+		return t.auth(ctx)
+	}
+	actx, cancel := context.WithTimeout(ctx, t.authTimeout)
+	defer cancel()
+	err := t.auth(actx)
+	if err != nil && ctx.Err() == nil && actx.Err() == context.DeadlineExceeded {
+		return &AuthError{Err: fmt.Errorf("%w after %s", ErrAuthTimeout, t.authTimeout)}
+	}
+	return err
+}
 
-	w := multipart.NewWriter(buf)
-	w.WriteField("login", t.Login)
-	w.WriteField("password", t.Password)
-	w.Close()
+// auth makes authorization request bound to given context
+func (t *Transport) auth(ctx context.Context) error {
+	reqURL := joinPath(t.host(ctx), &url.URL{Path: t.loginPath()})
+	login, password, err := t.credentials(ctx)
+	if err != nil {
+		return &AuthError{Err: fmt.Errorf("could not get credentials: %w", err)}
+	}
+	payload, contentType, err := t.loginBody(login, password)
+	if err != nil {
+		return &AuthError{Err: fmt.Errorf("could not encode credentials: %w", err)}
+	}
 
-	req, err := http.NewRequest(http.MethodPost, reqURL.String(), buf)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, reqURL.String(), bytes.NewReader(payload))
 	if err != nil {
-		return fmt.Errorf("auth: could not create request: %v", err)
+		return &AuthError{Err: fmt.Errorf("could not create request: %w", err)}
 	}
 	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Content-Type", w.FormDataContentType())
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("User-Agent", t.ua())
+	t.applyDefaults(req)
 
-	res, err := t.transport().RoundTrip(req)
+	if err := t.wait(ctx); err != nil {
+		return &AuthError{Err: err}
+	}
+	res, err := t.exchange(req)
 	if err != nil {
-		return fmt.Errorf("auth: request failed: %v", err)
+		return &AuthError{Err: fmt.Errorf("request failed: %w", err)}
 	}
 
 	defer res.Body.Close()
-	if res.StatusCode >= http.StatusBadRequest {
-		return fmt.Errorf("auth: invalid response: %d %s", res.StatusCode, http.StatusText(res.StatusCode))
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxErrorSize))
+	if err != nil {
+		return &AuthError{StatusCode: res.StatusCode, Err: fmt.Errorf("could not read response: %w", err)}
 	}
 	ar := authResp{}
-	if err := json.NewDecoder(res.Body).Decode(&ar); err != nil {
-		return fmt.Errorf("auth: could not decode response: %v", err)
+	decodeErr := json.Unmarshal(body, &ar)
+	if accountLocked(ar) {
+		return &AuthError{
+			StatusCode: res.StatusCode,
+			Message:    ar.Message,
+			Err:        ErrAccountLocked,
+			RetryAfter: lockWindow(res, ar, t.clock()),
+		}
+	}
+	if res.StatusCode == http.StatusTooManyRequests {
+		return &AuthError{StatusCode: res.StatusCode, Message: ar.Message, Err: rateLimited(res, t.clock())}
+	}
+	if res.StatusCode >= http.StatusBadRequest {
+		ae := &AuthError{StatusCode: res.StatusCode}
+		if decodeErr == nil {
+			ae.Message = ar.Message
+		}
+		// bot protection often answers with 403 challenge page,
+		// which says nothing about credentials
+		switch {
+		case !looksJSON(body) && challenged(res.Header.Get("Content-Type"), body):
+			ae.Err = nonJSON(res, body)
+		case res.StatusCode == http.StatusUnauthorized || res.StatusCode == http.StatusForbidden:
+			ae.Err = ErrInvalidCredentials
+		case !looksJSON(body):
+			ae.Err = nonJSON(res, body)
+		}
+		return ae
+	}
+	if !looksJSON(body) {
+		return &AuthError{StatusCode: res.StatusCode, Err: nonJSON(res, body)}
+	}
+	if decodeErr != nil {
+		return &AuthError{StatusCode: res.StatusCode, Err: fmt.Errorf("could not decode response: %w", decodeErr)}
 	}
 	if !ar.Success {
-		return fmt.Errorf("auth: request failed: %s", ar.Message)
+		return &AuthError{StatusCode: res.StatusCode, Message: ar.Message, Err: ErrInvalidCredentials}
 	}
 	t.session.key = ar.Data.SessionKey
-	t.session.start = time.Now().Add(-time.Minute)
+	t.session.start = t.clock()
+	t.session.host = t.host(ctx)
+	t.lastAuth.Store(t.session.start.UnixNano())
+	t.authCount.Add(1)
 	return nil
 }
 
+func (t *Transport) lifetime() time.Duration {
+	if t.sessionLifetime <= 0 {
+		return SessionLifetime
+	}
+	return t.sessionLifetime
+}
+
+// validFor returns duration session is considered valid for,
+// which is session lifetime reduced by safety margin
+func (t *Transport) validFor() time.Duration {
+	skew := t.sessionSkew
+	switch {
+	case skew == 0:
+		skew = DefaultSessionSkew
+	case skew < 0:
+		skew = 0
+	}
+	return t.lifetime() - skew
+}
+
+func (t *Transport) loginPath() string {
+	if len(t.loginURLPath) == 0 {
+		return DefaultLoginPath
+	}
+	return t.loginURLPath
+}
+
+func (t *Transport) credentialFields() (string, string) {
+	login, password := t.loginField, t.passwordField
+	if len(login) == 0 {
+		login = DefaultLoginField
+	}
+	if len(password) == 0 {
+		password = DefaultPasswordField
+	}
+	return login, password
+}
+
+func (t *Transport) clock() time.Time {
+	if t.now == nil {
+		return time.Now()
+	}
+	return t.now()
+}
+
+func (t *Transport) ua() string {
+	if len(t.userAgent) == 0 {
+		return DefaultUserAgent
+	}
+	return t.userAgent
+}
+
+func (t *Transport) acceptType() string {
+	if len(t.accept) == 0 {
+		return "application/json"
+	}
+	return t.accept
+}
+
 func (t *Transport) baseURL() *url.URL {
 	if t.BaseURL == nil {
 		return DefaultBaseURL
@@ -133,16 +939,73 @@ func (t *Transport) baseURL() *url.URL {
 }
 
 func (t *Transport) transport() http.RoundTripper {
-	if t.Transport == nil {
-		return http.DefaultTransport
+	if t.Transport != nil {
+		return t.Transport
+	}
+	if t.tuned() {
+		t.tuneOnce.Do(t.tune)
+		return t.tunedTransport
+	}
+	return http.DefaultTransport
+}
+
+// addTrailingSlash adds trailing slash required by API to the URL path,
+// query and fragment are left as is
+func addTrailingSlash(u *url.URL) {
+	if len(u.Path) == 0 {
+		u.Path = "/"
+		return
 	}
-	return t.Transport
+	if strings.HasSuffix(u.Path, "/") {
+		return
+	}
+	u.Path += "/"
+	if len(u.RawPath) > 0 {
+		u.RawPath += "/"
+	}
+}
+
+// sessionExpired reports whether response is 401 Unauthorized or an API error
+// about expired session key. Response body is restored so it could be read
+// again by the caller
+func sessionExpired(res *http.Response) (bool, error) {
+	if res.StatusCode == http.StatusUnauthorized {
+		return true, nil
+	}
+	head, err := io.ReadAll(io.LimitReader(res.Body, maxErrorSize))
+	if err != nil {
+		return false, err
+	}
+	res.Body = readCloser{io.MultiReader(bytes.NewReader(head), res.Body), res.Body}
+	if len(head) >= maxErrorSize {
+		// error payloads are tiny, no need to decode data
+		return false, nil
+	}
+	er := errorResp{}
+	if err := json.Unmarshal(head, &er); err != nil {
+		return false, nil
+	}
+	return !er.Success && er.Code == expiredSessionCode, nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+type errorResp struct {
+	Success bool   `json:"success"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
 }
 
 type authResp struct {
 	Success bool   `json:"success"`
+	Code    string `json:"code"`
 	Message string `json:"message"`
 	Data    struct {
 		SessionKey string `json:"session_key"`
+		// RetryAfter is a lockout duration in seconds
+		RetryAfter int `json:"retry_after"`
 	} `json:"data"`
 }
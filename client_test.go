@@ -0,0 +1,879 @@
+package comagic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nk2ge5k/go-api-comagic/comagictest"
+)
+
+// roundTripFunc is an adapter allowing to use function as stub transport
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// stubResponse returns response to r with given status and body
+func stubResponse(r *http.Request, status int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Status:     http.StatusText(status),
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    r,
+	}
+}
+
+// loginStub returns stub transport answering every login with session
+// key key-<login number> after given delay, and every other request with
+// empty data. Logins are counted in logins
+func loginStub(logins *atomic.Int32, delay time.Duration) roundTripFunc {
+	return func(r *http.Request) (*http.Response, error) {
+		if strings.HasSuffix(r.URL.Path, DefaultLoginPath) {
+			n := logins.Add(1)
+			time.Sleep(delay)
+			return stubResponse(r, http.StatusOK, fmt.Sprintf(`{"success":true,"data":{"session_key":"key-%d"}}`, n)), nil
+		}
+		return stubResponse(r, http.StatusOK, `{"success":true,"data":[]}`), nil
+	}
+}
+
+func TestConcurrentAuth(t *testing.T) {
+	var logins atomic.Int32
+	c := New("login", "password", WithTransport(loginStub(&logins, 20*time.Millisecond)))
+	var wg sync.WaitGroup
+	errs := make(chan error, 50)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, _ := http.NewRequest(http.MethodGet, "/api/x/", nil)
+			res, err := c.Do(req)
+			if err != nil {
+				errs <- err
+				return
+			}
+			res.Body.Close()
+			if got := res.Request.URL.Query().Get("session_key"); got != "key-1" {
+				errs <- fmt.Errorf("request sent with session key %q, want key-1", got)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if n := logins.Load(); n != 1 {
+		t.Fatalf("got %d login requests, want exactly one", n)
+	}
+
+	// failed login is shared with requests waiting for it as well
+	logins.Store(0)
+	c = New("login", "wrong", WithTransport(roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if strings.HasSuffix(r.URL.Path, DefaultLoginPath) {
+			logins.Add(1)
+			time.Sleep(50 * time.Millisecond)
+			return stubResponse(r, http.StatusOK, `{"success":false,"message":"invalid login or password"}`), nil
+		}
+		t.Errorf("request %s is sent without session", r.URL.Path)
+		return stubResponse(r, http.StatusOK, `{"success":true,"data":[]}`), nil
+	})))
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, _ := http.NewRequest(http.MethodGet, "/api/x/", nil)
+			if _, err := c.Do(req); !errors.Is(err, ErrInvalidCredentials) {
+				t.Errorf("got error %v, want ErrInvalidCredentials", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := logins.Load(); n != 1 {
+		t.Fatalf("got %d login requests with invalid credentials, want exactly one", n)
+	}
+}
+
+// TestConcurrentReauth interleaves requests and session reads with forced
+// re-authorizations, run it with -race
+func TestConcurrentReauth(t *testing.T) {
+	srv := comagictest.NewServer(t, comagictest.WithData("/api/x/", nil))
+	tr := New("login", "password", WithBaseURL(srv.URL())).Transport.(*Transport)
+	c := &http.Client{Transport: tr}
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 100)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				switch {
+				case i == 0 && j%10 == 0:
+					srv.ExpireSession()
+				case i == 1 && j%10 == 5:
+					if err := tr.RefreshSession(ctx); err != nil {
+						errs <- err
+						return
+					}
+				case i%2 == 0:
+					tr.SessionKey()
+					tr.SessionExpiresAt()
+				default:
+					req, _ := http.NewRequest(http.MethodGet, "/api/x/", nil)
+					res, err := c.Do(req)
+					if err != nil {
+						errs <- err
+						return
+					}
+					res.Body.Close()
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if n := srv.Logins(); n < 2 {
+		t.Errorf("got %d logins, want session renewed", n)
+	}
+}
+
+func TestBaseURLValidation(t *testing.T) {
+	tests := []struct {
+		url  *url.URL
+		want string
+	}{
+		{&url.URL{Host: "api.comagic.ru"}, "has no scheme"},
+		{&url.URL{Scheme: "https", Host: "api.comagic.ru", RawQuery: "a=1"}, "has query"},
+		{&url.URL{Scheme: "https", Path: "/api"}, "has no host"},
+		{&url.URL{Scheme: "https", Host: "api.comagic.ru", Fragment: "x"}, "has fragment"},
+	}
+	for _, tt := range tests {
+		tr := New("login", "password", WithBaseURL(tt.url)).Transport.(*Transport)
+		err := tr.Authenticate(context.Background())
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("base url %q: got error %v, want %q", tt.url, err, tt.want)
+		}
+	}
+	if _, err := (Config{Login: "login", Password: "password", BaseURL: &url.URL{Host: "api.comagic.ru"}}).Build(); err == nil {
+		t.Error("Build accepted base url without scheme")
+	}
+}
+
+func TestBaseURLPath(t *testing.T) {
+	var mu sync.Mutex
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		w.Write([]byte(`{"success":true,"data":{"session_key":"key"}}`))
+	}))
+	defer srv.Close()
+
+	for _, base := range []string{srv.URL + "/proxy/comagic", srv.URL + "/proxy/comagic/"} {
+		paths = nil
+		u, _ := url.Parse(base)
+		c := New("login", "password", WithBaseURL(u))
+		for _, ref := range []string{"/api/virtual_numbers/", "api/sites"} {
+			req, _ := http.NewRequest(http.MethodGet, ref, nil)
+			res, err := c.Do(req)
+			if err != nil {
+				t.Fatalf("base %q: unexpected error: %v", base, err)
+			}
+			res.Body.Close()
+		}
+		if err := c.Transport.(*Transport).Logout(context.Background()); err != nil {
+			t.Fatalf("base %q: logout: %v", base, err)
+		}
+		want := []string{"/proxy/comagic/api/login/", "/proxy/comagic/api/virtual_numbers/", "/proxy/comagic/api/sites/", "/proxy/comagic/api/logout/"}
+		if strings.Join(paths, " ") != strings.Join(want, " ") {
+			t.Errorf("base %q: got paths %q, want %q", base, paths, want)
+		}
+	}
+}
+
+func TestJoinPath(t *testing.T) {
+	tests := []struct {
+		base, ref, want string
+	}{
+		{"https://api.comagic.ru", "/api/x/?a=1", "https://api.comagic.ru/api/x/?a=1"},
+		{"https://api.comagic.ru/", "api/x/", "https://api.comagic.ru/api/x/"},
+		{"https://proxy/comagic", "/api/x", "https://proxy/comagic/api/x"},
+		{"https://proxy/a%2Fb/", "/api/x%20y/", "https://proxy/a%2Fb/api/x%20y/"},
+	}
+	for _, tt := range tests {
+		base, _ := url.Parse(tt.base)
+		ref, _ := url.Parse(tt.ref)
+		if got := joinPath(base, ref).String(); got != tt.want {
+			t.Errorf("joinPath(%q, %q) = %q, want %q", tt.base, tt.ref, got, tt.want)
+		}
+	}
+}
+
+func TestExpiredSessionReplay(t *testing.T) {
+	var bodies []string
+	srv := comagictest.NewServer(t, comagictest.WithHandler("/api/x/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		comagictest.Respond(w, nil)
+	})))
+	tr := New("login", "password", WithBaseURL(srv.URL())).Transport.(*Transport)
+	if err := tr.Authenticate(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	srv.ExpireSession()
+
+	req, _ := http.NewRequest(http.MethodPost, "/api/x/", strings.NewReader("payload"))
+	res, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	res.Body.Close()
+	if srv.Logins() != 2 {
+		t.Errorf("got %d logins, want re-authorization", srv.Logins())
+	}
+	if len(bodies) != 1 || bodies[0] != "payload" {
+		t.Errorf("got replayed bodies %q, want [payload]", bodies)
+	}
+	if got := res.Request.URL.Query().Get("session_key"); got != srv.SessionKey() {
+		t.Errorf("request replayed with session key %q, want %q", got, srv.SessionKey())
+	}
+}
+
+func TestUnauthorizedReplay(t *testing.T) {
+	tests := []struct {
+		status   int
+		body     string
+		replayed bool
+	}{
+		{http.StatusUnauthorized, `{}`, true},
+		{http.StatusOK, `{"success":false,"code":"expired_session_key","message":"session expired"}`, true},
+		{http.StatusForbidden, `{"success":false,"message":"forbidden"}`, false},
+		{http.StatusNotFound, `{}`, false},
+		{http.StatusBadRequest, `{"success":false,"message":"bad request"}`, false},
+	}
+	for _, tt := range tests {
+		var logins, requests atomic.Int32
+		stub := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			if strings.HasSuffix(r.URL.Path, DefaultLoginPath) {
+				return loginStub(&logins, 0)(r)
+			}
+			if requests.Add(1) == 1 {
+				return stubResponse(r, tt.status, tt.body), nil
+			}
+			return stubResponse(r, http.StatusOK, `{"success":true,"data":[]}`), nil
+		})
+		c := New("login", "password", WithTransport(stub))
+		req, _ := http.NewRequest(http.MethodGet, "/api/x/", nil)
+		res, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("status %d: unexpected error: %v", tt.status, err)
+		}
+		res.Body.Close()
+		wantLogins, wantStatus := int32(1), tt.status
+		if tt.replayed {
+			wantLogins, wantStatus = 2, http.StatusOK
+		}
+		if n := logins.Load(); n != wantLogins {
+			t.Errorf("status %d %s: got %d logins, want %d", tt.status, tt.body, n, wantLogins)
+		}
+		if res.StatusCode != wantStatus {
+			t.Errorf("status %d %s: got response status %d, want %d", tt.status, tt.body, res.StatusCode, wantStatus)
+		}
+	}
+}
+
+func TestAuthRetries(t *testing.T) {
+	for _, tt := range []struct {
+		opts   []func(*Transport)
+		logins int32
+	}{
+		{nil, 1 + DefaultAuthRetries},
+		{[]func(*Transport){WithAuthRetries(3)}, 4},
+		{[]func(*Transport){WithAuthRetries(0)}, 1},
+	} {
+		var logins atomic.Int32
+		stub := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			if strings.HasSuffix(r.URL.Path, DefaultLoginPath) {
+				return loginStub(&logins, 0)(r)
+			}
+			return stubResponse(r, http.StatusOK, `{"success":false,"code":"expired_session_key"}`), nil
+		})
+		c := New("login", "password", append(tt.opts, WithTransport(stub))...)
+		req, _ := http.NewRequest(http.MethodGet, "/api/x/", nil)
+		res, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		res.Body.Close()
+		if n := logins.Load(); n != tt.logins {
+			t.Errorf("got %d logins, want %d", n, tt.logins)
+		}
+	}
+}
+
+func TestAuthContext(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	base := newLoginServer(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	})
+	c := New("login", "password", WithBaseURL(base))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "/api/x/", nil)
+
+	start := time.Now()
+	_, err := c.Do(req)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got error %v, want context deadline", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("round trip returned after %s, login is not cancelled with request", d)
+	}
+}
+
+func TestAuthCancel(t *testing.T) {
+	started := make(chan struct{})
+	stub := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		close(started)
+		<-r.Context().Done()
+		return nil, r.Context().Err()
+	})
+	tr := New("login", "password", WithTransport(stub)).Transport.(*Transport)
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "/api/x/", nil)
+	if _, err := tr.RoundTrip(req); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got error %v, want context cancellation", err)
+	}
+	if len(tr.SessionKey()) > 0 {
+		t.Error("session is established by cancelled login")
+	}
+}
+
+func TestAuthJitter(t *testing.T) {
+	const jitter = 50 * time.Millisecond
+	for _, max := range []time.Duration{0, jitter} {
+		var logins atomic.Int32
+		tr := New("login", "password", WithTransport(loginStub(&logins, 0)), WithAuthJitter(max)).Transport.(*Transport)
+		start := time.Now()
+		if err := tr.Authenticate(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if d := time.Since(start); d > jitter+100*time.Millisecond || (max == 0 && d >= jitter) {
+			t.Errorf("jitter %s: first authorization took %s", max, d)
+		}
+		// only the first authorization is delayed
+		start = time.Now()
+		if err := tr.RefreshSession(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if d := time.Since(start); d >= jitter {
+			t.Errorf("jitter %s: renewal took %s", max, d)
+		}
+	}
+}
+
+func TestAuthJitterContext(t *testing.T) {
+	var logins atomic.Int32
+	tr := New("login", "password", WithTransport(loginStub(&logins, 0)), WithAuthJitter(time.Hour)).Transport.(*Transport)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := tr.Authenticate(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got error %v, want context deadline during jitter", err)
+	}
+	if n := logins.Load(); n != 0 {
+		t.Errorf("got %d logins, want none before jitter elapsed", n)
+	}
+}
+
+func TestSessionLifetime(t *testing.T) {
+	var logins atomic.Int32
+	c := New("login", "password", WithTransport(loginStub(&logins, 0)),
+		WithSessionLifetime(10*time.Millisecond), WithSessionSkew(-1))
+	get := func() {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, "/api/x/", nil)
+		res, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		res.Body.Close()
+	}
+	get()
+	time.Sleep(20 * time.Millisecond)
+	get()
+	if n := logins.Load(); n != 2 {
+		t.Fatalf("got %d logins, want fresh login after session lifetime", n)
+	}
+}
+
+func TestSessionLifetimeDefault(t *testing.T) {
+	for _, d := range []time.Duration{0, -time.Second} {
+		tr := New("login", "password", WithSessionLifetime(d)).Transport.(*Transport)
+		if got := tr.lifetime(); got != SessionLifetime {
+			t.Errorf("lifetime %s: got %s, want SessionLifetime", d, got)
+		}
+	}
+}
+
+func TestToken(t *testing.T) {
+	for _, tt := range []struct {
+		opts   []func(*Transport)
+		query  string
+		header string
+	}{
+		{nil, "token", ""},
+		{[]func(*Transport){WithTokenInHeader()}, "", "Bearer token"},
+	} {
+		var logins atomic.Int32
+		c := New("", "", append(tt.opts, WithToken("token"), WithTransport(loginStub(&logins, 0)))...)
+		for i := 0; i < 2; i++ {
+			req, _ := http.NewRequest(http.MethodGet, "/api/x/", nil)
+			res, err := c.Do(req)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			res.Body.Close()
+			if got := res.Request.URL.Query().Get("access_token"); got != tt.query {
+				t.Errorf("got access_token parameter %q, want %q", got, tt.query)
+			}
+			if got := res.Request.Header.Get("Authorization"); got != tt.header {
+				t.Errorf("got Authorization header %q, want %q", got, tt.header)
+			}
+		}
+		if n := logins.Load(); n != 0 {
+			t.Errorf("got %d logins in token mode", n)
+		}
+	}
+}
+
+func TestTokenWithPassword(t *testing.T) {
+	var logins atomic.Int32
+	c := New("login", "password", WithToken("token"), WithTransport(loginStub(&logins, 0)))
+	req, _ := http.NewRequest(http.MethodGet, "/api/x/", nil)
+	_, err := c.Do(req)
+	if err == nil || !strings.Contains(err.Error(), "mutually exclusive") {
+		t.Fatalf("got error %v, want token and login/password conflict", err)
+	}
+	if n := logins.Load(); n != 0 {
+		t.Errorf("got %d logins with invalid configuration", n)
+	}
+}
+
+func TestDefaultBaseURL(t *testing.T) {
+	if DefaultBaseURL.Scheme != "https" {
+		t.Fatalf("got default base url scheme %q, want https", DefaultBaseURL.Scheme)
+	}
+	var login *url.URL
+	stub := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		login = r.URL
+		return stubResponse(r, http.StatusOK, `{"success":true,"data":{"session_key":"key"}}`), nil
+	})
+	tr := New("login", "password", WithTransport(stub)).Transport.(*Transport)
+	if err := tr.Authenticate(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if login.Scheme != "https" || login.Host != DefaultBaseURL.Host {
+		t.Errorf("credentials sent to %q, want https default host", login)
+	}
+}
+
+func TestUserAgent(t *testing.T) {
+	for _, tt := range []struct {
+		opts []func(*Transport)
+		want string
+	}{
+		{nil, DefaultUserAgent},
+		{[]func(*Transport){WithUserAgent("reports/1.0")}, "reports/1.0"},
+	} {
+		var agents []string
+		stub := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			agents = append(agents, r.Header.Get("User-Agent"))
+			return stubResponse(r, http.StatusOK, `{"success":true,"data":{"session_key":"key"}}`), nil
+		})
+		c := New("login", "password", append(tt.opts, WithTransport(stub))...)
+		req, _ := http.NewRequest(http.MethodGet, "/api/x/", nil)
+		res, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		res.Body.Close()
+		if len(agents) != 2 || agents[0] != tt.want || agents[1] != tt.want {
+			t.Errorf("got login and request user agents %q, want %q", agents, tt.want)
+		}
+
+		agents = nil
+		req, _ = http.NewRequest(http.MethodGet, "/api/x/", nil)
+		req.Header.Set("User-Agent", "caller/2.0")
+		res, err = c.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		res.Body.Close()
+		if len(agents) != 1 || agents[0] != "caller/2.0" {
+			t.Errorf("got user agents %q, want one set by caller", agents)
+		}
+	}
+}
+
+func TestAccept(t *testing.T) {
+	for _, tt := range []struct {
+		opts   []func(*Transport)
+		header string
+		want   string
+	}{
+		{nil, "", "application/json"},
+		{nil, "text/csv", "text/csv"},
+		{[]func(*Transport){WithAccept("text/csv")}, "", "text/csv"},
+		{[]func(*Transport){WithAccept("text/csv")}, "application/xml", "application/xml"},
+	} {
+		accepts := map[string]string{}
+		stub := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			accepts[r.URL.Path] = r.Header.Get("Accept")
+			return stubResponse(r, http.StatusOK, `{"success":true,"data":{"session_key":"key"}}`), nil
+		})
+		c := New("login", "password", append(tt.opts, WithTransport(stub))...)
+		req, _ := http.NewRequest(http.MethodGet, "/api/x/", nil)
+		if len(tt.header) > 0 {
+			req.Header.Set("Accept", tt.header)
+		}
+		res, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		res.Body.Close()
+		if got := accepts["/api/x/"]; got != tt.want {
+			t.Errorf("header %q: got Accept %q, want %q", tt.header, got, tt.want)
+		}
+		if got := accepts[DefaultLoginPath]; got != "application/json" {
+			t.Errorf("header %q: got login Accept %q, want application/json", tt.header, got)
+		}
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	var sent []*http.Request
+	stub := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		sent = append(sent, r)
+		return stubResponse(r, http.StatusOK, `{"success":true,"data":{"session_key":"key"}}`), nil
+	})
+	c := New("login", "password", WithTransport(stub),
+		WithDefaultQuery(url.Values{"partner": {"42"}, "region": {"ru"}, "session_key": {"default"}}),
+		WithDefaultHeader("X-Partner", "42"), WithDefaultHeader("X-Region", "ru"))
+	req, _ := http.NewRequest(http.MethodGet, "/api/x/?region=eu", nil)
+	req.Header.Set("X-Region", "eu")
+	res, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	res.Body.Close()
+	if len(sent) != 2 {
+		t.Fatalf("got %d requests, want login and API request", len(sent))
+	}
+	if login := sent[0]; login.URL.Query().Get("partner") != "42" || login.Header.Get("X-Partner") != "42" {
+		t.Errorf("got login request %q with headers %v, want defaults applied", login.URL, login.Header)
+	}
+	q, h := sent[1].URL.Query(), sent[1].Header
+	if q.Get("partner") != "42" || h.Get("X-Partner") != "42" {
+		t.Errorf("got request %q with headers %v, want defaults applied", sent[1].URL, h)
+	}
+	if q.Get("region") != "eu" || h.Get("X-Region") != "eu" {
+		t.Errorf("got region %q and header %q, want values of request to take precedence", q.Get("region"), h.Get("X-Region"))
+	}
+	if q["session_key"][0] != "key" || len(q["session_key"]) != 1 {
+		t.Errorf("got session_key %q, want only session key of transport", q["session_key"])
+	}
+}
+
+func TestAddTrailingSlash(t *testing.T) {
+	tests := []struct {
+		url, want string
+	}{
+		{"/api/calls_report", "/api/calls_report/"},
+		{"/api/calls_report/", "/api/calls_report/"},
+		{"", "/"},
+		{"/api/calls_report?a=1/", "/api/calls_report/?a=1/"},
+		{"/api/a%2Fb", "/api/a%2Fb/"},
+		{"/api/x#frag", "/api/x/#frag"},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.url)
+		addTrailingSlash(u)
+		if got := u.String(); got != tt.want {
+			t.Errorf("addTrailingSlash(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestWithTrailingSlash(t *testing.T) {
+	for _, tt := range []struct {
+		enabled   bool
+		ref, want string
+	}{
+		{true, "/api/x", "/api/x/"},
+		{false, "/api/x.csv", "/api/x.csv"},
+	} {
+		var path string
+		stub := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			path = r.URL.Path
+			return stubResponse(r, http.StatusOK, `{"success":true,"data":{"session_key":"key"}}`), nil
+		})
+		c := New("login", "password", WithTransport(stub), WithTrailingSlash(tt.enabled))
+		req, _ := http.NewRequest(http.MethodGet, tt.ref, nil)
+		res, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		res.Body.Close()
+		if path != tt.want {
+			t.Errorf("trailing slash %v: got path %q, want %q", tt.enabled, path, tt.want)
+		}
+	}
+}
+
+func TestClock(t *testing.T) {
+	var logins atomic.Int32
+	clock := newFakeClock()
+	c := New("login", "password", WithTransport(loginStub(&logins, 0)), WithClock(clock.Now))
+	get := func() {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, "/api/x/", nil)
+		res, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		res.Body.Close()
+	}
+	get()
+	clock.Advance(SessionLifetime - DefaultSessionSkew - time.Second)
+	get()
+	if n := logins.Load(); n != 1 {
+		t.Fatalf("got %d logins, want session reused before expiry", n)
+	}
+	clock.Advance(time.Second)
+	get()
+	if n := logins.Load(); n != 2 {
+		t.Fatalf("got %d logins, want re-authorization after session lifetime", n)
+	}
+}
+
+func TestAuthTimeout(t *testing.T) {
+	var logins atomic.Int32
+	stub := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		logins.Add(1)
+		select {
+		case <-r.Context().Done():
+			return nil, r.Context().Err()
+		case <-time.After(time.Second):
+			return stubResponse(r, http.StatusOK, `{"success":true,"data":{"session_key":"key"}}`), nil
+		}
+	})
+	c := New("login", "password", WithTransport(stub), WithAuthTimeout(20*time.Millisecond))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "/api/x/", nil)
+	start := time.Now()
+	_, err := c.Do(req)
+	if !errors.Is(err, ErrAuthTimeout) {
+		t.Fatalf("got error %v, want ErrAuthTimeout", err)
+	}
+	ae := &AuthError{}
+	if !errors.As(err, &ae) || !ae.Timeout() {
+		t.Fatalf("got error %v, want AuthError reporting timeout", err)
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("login is not limited by auth timeout, request failed after %s", d)
+	}
+}
+
+func TestAuthTimeoutRequestContext(t *testing.T) {
+	stub := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		<-r.Context().Done()
+		return nil, r.Context().Err()
+	})
+	c := New("login", "password", WithTransport(stub), WithAuthTimeout(time.Second))
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "/api/x/", nil)
+	_, err := c.Do(req)
+	if errors.Is(err, ErrAuthTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got error %v, want deadline of request context", err)
+	}
+}
+
+func TestWithHTTPClient(t *testing.T) {
+	var conns atomic.Int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"success":true,"data":{"session_key":"key"}}`))
+	}))
+	srv.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+	base, _ := url.Parse(srv.URL)
+
+	rt := &http.Transport{MaxIdleConnsPerHost: 1}
+	defer rt.CloseIdleConnections()
+	jar, _ := cookiejar.New(nil)
+	hc := &http.Client{Transport: rt, Timeout: 5 * time.Second, Jar: jar}
+	c := New("login", "password", WithBaseURL(base), WithHTTPClient(hc))
+	if c.Timeout != hc.Timeout || c.Jar != jar {
+		t.Errorf("got timeout %s and jar %v, want settings of given client", c.Timeout, c.Jar)
+	}
+	if c.Transport.(*Transport).transport() != rt {
+		t.Fatal("transport of given client is not used as underlying one")
+	}
+	for i := 0; i < 3; i++ {
+		req, _ := http.NewRequest(http.MethodGet, "/api/x/", nil)
+		res, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+	}
+	if n := conns.Load(); n != 1 {
+		t.Errorf("got %d connections for login and 3 requests, want pooled connection reused", n)
+	}
+}
+
+func TestWithHTTPClientNil(t *testing.T) {
+	base := newLoginServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"success":true,"data":{"session_key":"key"}}`))
+	})
+	for _, d := range []Doer{
+		New("login", "password", WithBaseURL(base), WithHTTPClient(nil)),
+		NewClient(nil).doer,
+	} {
+		req, _ := http.NewRequest(http.MethodGet, base.JoinPath("/api/x/").String(), nil)
+		res, err := d.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		res.Body.Close()
+	}
+}
+
+func TestSessionSkew(t *testing.T) {
+	for _, tt := range []struct {
+		skew    time.Duration
+		elapsed time.Duration
+		logins  int32
+	}{
+		{0, 2 * time.Hour, 1},
+		{0, SessionLifetime - DefaultSessionSkew, 2},
+		{time.Hour, 2*time.Hour - time.Second, 1},
+		{time.Hour, 2 * time.Hour, 2},
+		{-1, SessionLifetime - time.Second, 1},
+	} {
+		var logins atomic.Int32
+		clock := newFakeClock()
+		c := New("login", "password", WithTransport(loginStub(&logins, 0)), WithClock(clock.Now), WithSessionSkew(tt.skew))
+		for i := 0; i < 2; i++ {
+			req, _ := http.NewRequest(http.MethodGet, "/api/x/", nil)
+			res, err := c.Do(req)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			res.Body.Close()
+			clock.Advance(tt.elapsed)
+		}
+		if n := logins.Load(); n != tt.logins {
+			t.Errorf("skew %s, request after %s: got %d logins, want %d", tt.skew, tt.elapsed, n, tt.logins)
+		}
+	}
+}
+
+func TestClientTimeout(t *testing.T) {
+	if c := New("login", "password"); c.Timeout != 0 {
+		t.Errorf("got timeout %s, want none by default", c.Timeout)
+	}
+	h := newFakeHost(t)
+	h.loginDelay = 300 * time.Millisecond
+	c := New("login", "password", WithBaseURL(h.URL()), WithClientTimeout(50*time.Millisecond))
+	if c.Timeout != 50*time.Millisecond {
+		t.Fatalf("got timeout %s, want 50ms", c.Timeout)
+	}
+	start := time.Now()
+	req, _ := http.NewRequest(http.MethodGet, "/api/x/", nil)
+	if _, err := c.Do(req); err == nil {
+		t.Fatal("got no error of hung authorization")
+	}
+	if d := time.Since(start); d > 250*time.Millisecond {
+		t.Errorf("request was stopped in %s, want client timeout", d)
+	}
+}
+
+func TestSessionParam(t *testing.T) {
+	tests := []struct {
+		opts   []func(*Transport)
+		param  string
+		header string
+	}{
+		{nil, "session_key", ""},
+		{[]func(*Transport){WithSessionParam("token")}, "token", ""},
+		{[]func(*Transport){WithSessionInHeader("X-Session-Key")}, "", "X-Session-Key"},
+	}
+	for _, tt := range tests {
+		var logins atomic.Int32
+		var sent *http.Request
+		stub := loginStub(&logins, 0)
+		c := New("login", "password", append(tt.opts, WithTransport(roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			if !strings.HasSuffix(r.URL.Path, DefaultLoginPath) {
+				sent = r
+			}
+			return stub(r)
+		})))...)
+		req, _ := http.NewRequest(http.MethodGet, "/api/x/?a=1", nil)
+		res, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		res.Body.Close()
+		q := sent.URL.Query()
+		if len(tt.param) > 0 && q.Get(tt.param) != "key-1" {
+			t.Errorf("param %q: got query %v, want session key in it", tt.param, q)
+		}
+		if tt.param != "session_key" && q.Has("session_key") {
+			t.Errorf("param %q header %q: got default session_key parameter", tt.param, tt.header)
+		}
+		if len(tt.header) > 0 {
+			if got := sent.Header.Get(tt.header); got != "key-1" {
+				t.Errorf("header %q: got %q, want session key", tt.header, got)
+			}
+			if len(q) != 1 {
+				t.Errorf("header %q: got query %v, want session key not in query", tt.header, q)
+			}
+		}
+	}
+}
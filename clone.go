@@ -0,0 +1,65 @@
+package comagic
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// Clone returns transport with the same configuration but without session:
+// clones never share session state, so session store is not copied either.
+// Background refresh of the clone is started if it is configured
+// and must be stopped with Close
+func (t *Transport) Clone() *Transport {
+	c := &Transport{
+		Login:     t.Login,
+		Password:  t.Password,
+		Transport: t.Transport,
+		config:    t.config,
+	}
+	if t.BaseURL != nil {
+		u := *t.BaseURL
+		c.BaseURL = &u
+	}
+	if t.defaultQuery != nil {
+		c.defaultQuery = url.Values{}
+		for k, vs := range t.defaultQuery {
+			c.defaultQuery[k] = append([]string(nil), vs...)
+		}
+	}
+	if t.defaultHeader != nil {
+		c.defaultHeader = t.defaultHeader.Clone()
+	}
+	c.requestHooks = append([]func(*http.Request) error(nil), t.requestHooks...)
+	c.responseHooks = append([]func(*http.Response) error(nil), t.responseHooks...)
+	if t.retry != nil {
+		p := *t.retry
+		c.retry = &p
+	}
+	if t.tlsConfig != nil {
+		c.tlsConfig = t.tlsConfig.Clone()
+	}
+	if t.proxy != nil {
+		u := *t.proxy
+		c.proxy = &u
+	}
+	c.failover = make([]*url.URL, len(t.failover))
+	for i, u := range t.failover {
+		u := *u
+		c.failover[i] = &u
+	}
+	c.store = nil
+	if c.refreshInterval > 0 {
+		c.startRefresh()
+	}
+	return c
+}
+
+// CloneWithCredentials returns clone of the transport using given credentials
+// instead of configured ones or credential provider
+func (t *Transport) CloneWithCredentials(login, password string) *Transport {
+	c := t.Clone()
+	c.Login = login
+	c.Password = password
+	c.credentialProvider = nil
+	return c
+}
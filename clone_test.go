@@ -0,0 +1,57 @@
+package comagic
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+func TestClone(t *testing.T) {
+	var mu sync.Mutex
+	agents := map[string]string{}
+	stub := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Path == DefaultLoginPath {
+			if err := r.ParseMultipartForm(1 << 10); err != nil {
+				t.Errorf("invalid login request: %v", err)
+			}
+			login := r.FormValue("login")
+			mu.Lock()
+			agents[login] = r.Header.Get("User-Agent")
+			mu.Unlock()
+			return stubResponse(r, http.StatusOK, `{"success":true,"data":{"session_key":"key-`+login+`"}}`), nil
+		}
+		return stubResponse(r, http.StatusOK, `{"success":true,"data":[]}`), nil
+	})
+	tr := New("first", "password", WithTransport(stub), WithUserAgent("agent/1.0"),
+		WithRetry(RetryPolicy{MaxAttempts: 5})).Transport.(*Transport)
+	ctx := context.Background()
+	if err := tr.Authenticate(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	c := tr.CloneWithCredentials("second", "password")
+	if len(c.SessionKey()) > 0 {
+		t.Fatal("clone shares session of original transport")
+	}
+	if c.retry == tr.retry || c.retry.MaxAttempts != 5 {
+		t.Errorf("got retry policy %+v, want own copy of original one", c.retry)
+	}
+	if err := c.Authenticate(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := c.SessionKey(); got != "key-second" {
+		t.Errorf("got clone session key %q, want key-second", got)
+	}
+	if got := tr.SessionKey(); got != "key-first" {
+		t.Errorf("got original session key %q after clone authorization, want key-first", got)
+	}
+	if got := agents["second"]; got != "agent/1.0" {
+		t.Errorf("got clone user agent %q, want agent/1.0", got)
+	}
+
+	c.invalidate(c.SessionKey())
+	if got := tr.SessionKey(); got != "key-first" {
+		t.Errorf("got original session key %q after clone invalidation, want key-first", got)
+	}
+}
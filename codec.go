@@ -0,0 +1,55 @@
+package comagic
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+// JSONCodec encodes request bodies and decodes responses of Client,
+// it allows to replace encoding/json with faster implementation.
+// Codec decodes response envelopes, streamed reports and values of types
+// without UnmarshalJSON method, but records like Call, Site or Employee
+// decode themselves with encoding/json to collect unknown fields into
+// Extra, so codec does not speed up their decoding
+type JSONCodec interface {
+	Marshal(v interface{}) ([]byte, error)
+	NewDecoder(r io.Reader) JSONDecoder
+}
+
+// JSONDecoder is a streaming JSON decoder, *json.Decoder implements it
+type JSONDecoder interface {
+	Decode(v interface{}) error
+	More() bool
+	Token() (json.Token, error)
+}
+
+// WithJSONCodec is an option function for setting JSON codec used
+// by Client, encoding/json is used by default.
+// See JSONCodec for types which are decoded by encoding/json anyway
+func WithJSONCodec(codec JSONCodec) func(*Client) {
+	return func(c *Client) { c.jsonCodec = codec }
+}
+
+// stdCodec is a JSONCodec backed by encoding/json
+type stdCodec struct{}
+
+func (stdCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (stdCodec) NewDecoder(r io.Reader) JSONDecoder {
+	return json.NewDecoder(r)
+}
+
+func (c *Client) codec() JSONCodec {
+	if c.jsonCodec == nil {
+		return stdCodec{}
+	}
+	return c.jsonCodec
+}
+
+// unmarshal decodes b into v with client codec
+func (c *Client) unmarshal(b []byte, v interface{}) error {
+	return c.codec().NewDecoder(bytes.NewReader(b)).Decode(v)
+}
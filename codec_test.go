@@ -0,0 +1,77 @@
+package comagic
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nk2ge5k/go-api-comagic/comagictest"
+)
+
+type recordingCodec struct {
+	stdCodec
+	marshals atomic.Int32
+	decoders atomic.Int32
+}
+
+func (c *recordingCodec) Marshal(v interface{}) ([]byte, error) {
+	c.marshals.Add(1)
+	return c.stdCodec.Marshal(v)
+}
+
+func (c *recordingCodec) NewDecoder(r io.Reader) JSONDecoder {
+	c.decoders.Add(1)
+	return c.stdCodec.NewDecoder(r)
+}
+
+func TestJSONCodec(t *testing.T) {
+	srv := comagictest.NewServer(t,
+		comagictest.WithData("/api/x/", map[string]int{"n": 1}),
+		comagictest.WithData("/api/calls_report/", []map[string]interface{}{{"id": 1}, {"id": 2}}),
+	)
+	codec := &recordingCodec{}
+	c := NewClient(New("login", "password", WithBaseURL(srv.URL())), WithJSONCodec(codec))
+	ctx := context.Background()
+
+	out := map[string]int{}
+	if err := c.GetJSON(ctx, "/api/x/", nil, &out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out["n"] != 1 {
+		t.Fatalf("got %v, want n=1", out)
+	}
+	if codec.decoders.Load() == 0 {
+		t.Fatal("codec was not used to decode response")
+	}
+
+	if err := c.PostJSON(ctx, "/api/x/", map[string]int{"n": 2}, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if codec.marshals.Load() != 1 {
+		t.Fatalf("got %d marshals, want request body encoded with codec", codec.marshals.Load())
+	}
+
+	decoders := codec.decoders.Load()
+	calls := 0
+	err := c.CallsReportStream(ctx, CallsReportRequest{DateFrom: time.Now().Add(-time.Hour), DateTill: time.Now()}, func(Call) error {
+		calls++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("got %d calls, want 2", calls)
+	}
+	if codec.decoders.Load() == decoders {
+		t.Fatal("codec was not used to decode stream")
+	}
+}
+
+func TestDefaultJSONCodec(t *testing.T) {
+	if _, ok := (&Client{}).codec().(stdCodec); !ok {
+		t.Fatal("client without codec does not use encoding/json")
+	}
+}
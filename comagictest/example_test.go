@@ -0,0 +1,63 @@
+package comagictest_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	comagic "github.com/nk2ge5k/go-api-comagic"
+	"github.com/nk2ge5k/go-api-comagic/comagictest"
+)
+
+func Example() {
+	// in tests pass *testing.T instead of nil, server is closed on cleanup then
+	srv := comagictest.NewServer(nil,
+		comagictest.WithCredentials("login", "password"),
+		comagictest.WithData("/api/calls_report/", []map[string]interface{}{
+			{"id": 1, "numa": "74950000000", "call_date": "2024-01-01 10:00:00"},
+			{"id": 2, "numa": "74950000001", "call_date": "2024-01-01 11:30:00"},
+		}),
+	)
+	defer srv.Close()
+
+	c := comagic.NewClient(comagic.New("login", "password", comagic.WithBaseURL(srv.URL())))
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, comagic.DefaultLocation)
+	report, err := c.CallsReport(context.Background(), comagic.CallsReportRequest{
+		DateFrom: from,
+		DateTill: from.AddDate(0, 0, 1),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, call := range report.Calls {
+		fmt.Println(call.ID, call.CallerNumber, call.CallDate.Format(comagic.DateTimeLayout))
+	}
+	fmt.Println("logins:", srv.Logins())
+	// Output:
+	// 1 74950000000 2024-01-01 10:00:00
+	// 2 74950000001 2024-01-01 11:30:00
+	// logins: 1
+}
+
+func ExampleServer_ExpireSession() {
+	srv := comagictest.NewServer(nil, comagictest.WithData("/api/sites/", []map[string]interface{}{
+		{"id": 1, "domain": "example.com"},
+	}))
+	defer srv.Close()
+
+	c := comagic.NewClient(comagic.New("login", "password", comagic.WithBaseURL(srv.URL())))
+	ctx := context.Background()
+	if _, err := c.Sites(ctx); err != nil {
+		log.Fatal(err)
+	}
+	// client re-authorizes when server reports expired session
+	srv.ExpireSession()
+	sites, err := c.Sites(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(len(sites), "logins:", srv.Logins())
+	// Output:
+	// 1 logins: 2
+}
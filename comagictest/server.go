@@ -0,0 +1,224 @@
+// Package comagictest provides fake comagic API server for tests.
+//
+// Server implements login handshake and serves canned responses,
+// so client could be tested end to end:
+//
+//	srv := comagictest.NewServer(t,
+//		comagictest.WithData("/api/calls_report/", []map[string]interface{}{
+//			{"id": 1, "numa": "74950000000"},
+//		}),
+//	)
+//	c := comagic.NewClient(comagic.New("login", "password", comagic.WithBaseURL(srv.URL())))
+//	report, err := c.CallsReport(ctx, comagic.CallsReportRequest{DateFrom: from, DateTill: till})
+//
+// Requests with session key other than issued one fail the test unless
+// session was expired with ExpireSession or check is relaxed with
+// WithSessionKeyCheck, so re-authorization could be tested too
+package comagictest
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// SessionKey is a session key issued by server by default
+const SessionKey = "comagictest-session-key"
+
+// LoginPath is a path of login endpoint
+const LoginPath = "/api/login/"
+
+// Server is a fake comagic API server
+type Server struct {
+	*httptest.Server
+
+	tb       testing.TB
+	login    string
+	password string
+	handlers map[string]http.Handler
+	lenient  bool
+
+	mu         sync.Mutex
+	logins     int
+	sessionKey string
+	expired    map[string]bool
+	sessions   int
+}
+
+// WithCredentials is an option function for setting accepted credentials,
+// by default any credentials are accepted
+func WithCredentials(login, password string) func(*Server) {
+	return func(s *Server) {
+		s.login = login
+		s.password = password
+	}
+}
+
+// WithSessionKey is an option function for setting issued session key
+func WithSessionKey(key string) func(*Server) {
+	return func(s *Server) { s.sessionKey = key }
+}
+
+// WithSessionKeyCheck is an option function for switching test failure
+// on request with session key other than issued one, enabled by default.
+// Such requests are answered with expired_session_key error either way
+func WithSessionKeyCheck(enabled bool) func(*Server) {
+	return func(s *Server) { s.lenient = !enabled }
+}
+
+// WithHandler is an option function for setting handler of API endpoint,
+// handler is called only for requests with valid session key
+func WithHandler(path string, h http.Handler) func(*Server) {
+	return func(s *Server) { s.handlers[normalize(path)] = h }
+}
+
+// WithData is an option function for setting successful response data of API endpoint
+func WithData(path string, data interface{}) func(*Server) {
+	return WithHandler(path, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Respond(w, data)
+	}))
+}
+
+// NewServer starts fake API server which is closed on test cleanup.
+// Outside of tests tb could be nil, then server must be closed by
+// the caller and failed checks are logged
+func NewServer(tb testing.TB, opts ...func(*Server)) *Server {
+	s := &Server{
+		tb:         tb,
+		sessionKey: SessionKey,
+		handlers:   map[string]http.Handler{},
+		expired:    map[string]bool{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	if tb != nil {
+		tb.Cleanup(s.Close)
+	}
+	return s
+}
+
+// URL returns server base URL
+func (s *Server) URL() *url.URL {
+	u, err := url.Parse(s.Server.URL)
+	if err != nil {
+		// httptest server URL is always valid
+		panic(fmt.Sprintf("comagictest: invalid server URL: %v", err))
+	}
+	return u
+}
+
+// ExpireSession expires issued session key: requests with it are answered
+// with expired_session_key error and the next login issues a new key
+func (s *Server) ExpireSession() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expired[s.sessionKey] = true
+	s.sessions++
+	s.sessionKey = fmt.Sprintf("%s-%d", SessionKey, s.sessions)
+}
+
+// SessionKey returns session key issued by the next login
+func (s *Server) SessionKey() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessionKey
+}
+
+// Logins returns number of login requests served
+func (s *Server) Logins() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.logins
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	path := normalize(r.URL.Path)
+	if path == LoginPath {
+		s.serveLogin(w, r)
+		return
+	}
+	s.mu.Lock()
+	key := r.URL.Query().Get("session_key")
+	want, expired := s.sessionKey, s.expired[key]
+	s.mu.Unlock()
+	if key != want {
+		if !expired && !s.lenient {
+			s.errorf("comagictest: %s requested with session key %q, want %q", r.URL.Path, key, want)
+		}
+		RespondError(w, "expired_session_key", "invalid session key")
+		return
+	}
+	h, ok := s.handlers[path]
+	if !ok {
+		write(w, http.StatusNotFound, envelope{Code: "not_found", Message: "unknown endpoint " + r.URL.Path})
+		return
+	}
+	h.ServeHTTP(w, r)
+}
+
+func (s *Server) serveLogin(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.logins++
+	s.mu.Unlock()
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		RespondError(w, "invalid_request", err.Error())
+		return
+	}
+	if len(s.login) > 0 && (r.FormValue("login") != s.login || r.FormValue("password") != s.password) {
+		RespondError(w, "invalid_credentials", "invalid login or password")
+		return
+	}
+	Respond(w, map[string]string{"session_key": s.SessionKey()})
+}
+
+// errorf reports failed check to the test or logs it outside of tests
+func (s *Server) errorf(format string, args ...interface{}) {
+	if s.tb == nil {
+		log.Printf(format, args...)
+		return
+	}
+	s.tb.Errorf(format, args...)
+}
+
+// Respond writes successful API response envelope with given data
+func Respond(w http.ResponseWriter, data interface{}) {
+	write(w, http.StatusOK, envelope{Success: true, Data: data})
+}
+
+// RespondError writes failed API response envelope
+func RespondError(w http.ResponseWriter, code, message string) {
+	write(w, http.StatusOK, envelope{Code: code, Message: message})
+}
+
+type envelope struct {
+	Success bool        `json:"success"`
+	Code    string      `json:"code,omitempty"`
+	Message string      `json:"message,omitempty"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+func write(w http.ResponseWriter, status int, v envelope) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func normalize(path string) string {
+	if !strings.HasSuffix(path, "/") {
+		path += "/"
+	}
+	return path
+}
@@ -0,0 +1,65 @@
+package comagictest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// recordingTB records failures instead of failing the test
+type recordingTB struct {
+	testing.TB
+	errors []string
+}
+
+func (tb *recordingTB) Errorf(format string, args ...interface{}) {
+	tb.errors = append(tb.errors, fmt.Sprintf(format, args...))
+}
+
+func get(t *testing.T, s *Server, key string) string {
+	t.Helper()
+	res, err := http.Get(s.Server.URL + "/api/x/?session_key=" + key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	var env envelope
+	if err := json.NewDecoder(res.Body).Decode(&env); err != nil {
+		t.Fatal(err)
+	}
+	return env.Code
+}
+
+func TestSessionKeyCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   []func(*Server)
+		expire bool
+		errors int
+	}{
+		{name: "strict", errors: 1},
+		{name: "lenient", opts: []func(*Server){WithSessionKeyCheck(false)}},
+		{name: "expired", expire: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tb := &recordingTB{TB: t}
+			s := NewServer(tb, append(tt.opts, WithData("/api/x/", nil))...)
+			stale := "stale"
+			if tt.expire {
+				stale = s.SessionKey()
+				s.ExpireSession()
+			}
+			if code := get(t, s, stale); code != "expired_session_key" {
+				t.Errorf("got code %q for stale key, want expired_session_key", code)
+			}
+			if code := get(t, s, s.SessionKey()); code != "" {
+				t.Errorf("got code %q for valid key, want success", code)
+			}
+			if len(tb.errors) != tt.errors {
+				t.Errorf("got failures %q, want %d", tb.errors, tt.errors)
+			}
+		})
+	}
+}
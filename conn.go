@@ -0,0 +1,93 @@
+package comagic
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// WithTLSConfig is an option function for setting TLS config, e.g. with client
+// certificates. Config is applied to the clone of http.DefaultTransport keeping
+// its connection pool and timeouts settings, so it could not be combined
+// with WithTransport
+func WithTLSConfig(cfg *tls.Config) func(*Transport) {
+	return func(t *Transport) { t.tlsConfig = cfg }
+}
+
+// WithConnectionPool is an option function for setting connection pool limits
+// applied to the clone of http.DefaultTransport, non positive values keep
+// default ones. It could not be combined with WithTransport
+func WithConnectionPool(maxIdle, maxIdlePerHost int, idleTimeout time.Duration) func(*Transport) {
+	return func(t *Transport) {
+		t.pool = &connectionPool{
+			maxIdle:        maxIdle,
+			maxIdlePerHost: maxIdlePerHost,
+			idleTimeout:    idleTimeout,
+		}
+	}
+}
+
+// WithProxy is an option function for setting proxy requests are sent through,
+// it is applied to the clone of http.DefaultTransport. Without it proxy is taken
+// from HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables unless
+// custom transport is set by WithTransport or WithHTTPClient, which uses own
+// proxy settings, so WithProxy could not be combined with them
+func WithProxy(u *url.URL) func(*Transport) {
+	return func(t *Transport) { t.proxy = u }
+}
+
+// connectionPool holds connection pool limits
+type connectionPool struct {
+	maxIdle        int
+	maxIdlePerHost int
+	idleTimeout    time.Duration
+}
+
+// tunedSettings returns names of settings requiring tuned transport
+func (t *Transport) tunedSettings() []string {
+	var names []string
+	if t.tlsConfig != nil {
+		names = append(names, "TLS config")
+	}
+	if t.pool != nil {
+		names = append(names, "connection pool")
+	}
+	if t.proxy != nil {
+		names = append(names, "proxy")
+	}
+	return names
+}
+
+// tuned reports whether default transport has to be cloned and tuned
+func (t *Transport) tuned() bool {
+	return t.tlsConfig != nil || t.pool != nil || t.proxy != nil
+}
+
+// tune builds underlying transport from the clone of default one
+func (t *Transport) tune() {
+	var rt *http.Transport
+	if dt, ok := http.DefaultTransport.(*http.Transport); ok {
+		rt = dt.Clone()
+	} else {
+		rt = &http.Transport{Proxy: http.ProxyFromEnvironment}
+	}
+	if t.tlsConfig != nil {
+		rt.TLSClientConfig = t.tlsConfig.Clone()
+	}
+	if t.proxy != nil {
+		rt.Proxy = http.ProxyURL(t.proxy)
+	}
+	if p := t.pool; p != nil {
+		if p.maxIdle > 0 {
+			rt.MaxIdleConns = p.maxIdle
+		}
+		if p.maxIdlePerHost > 0 {
+			rt.MaxIdleConnsPerHost = p.maxIdlePerHost
+		}
+		if p.idleTimeout > 0 {
+			rt.IdleConnTimeout = p.idleTimeout
+		}
+	}
+	t.tunedTransport = rt
+}
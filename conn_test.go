@@ -0,0 +1,134 @@
+package comagic
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTLSConfig(t *testing.T) {
+	cfg := &tls.Config{ServerName: "api.comagic.ru", MinVersion: tls.VersionTLS12}
+	tr := New("login", "password", WithTLSConfig(cfg)).Transport.(*Transport)
+	rt, ok := tr.transport().(*http.Transport)
+	if !ok {
+		t.Fatalf("got transport %T, want *http.Transport", tr.transport())
+	}
+	if rt == http.DefaultTransport {
+		t.Fatal("TLS config is applied to default transport")
+	}
+	if rt.TLSClientConfig == nil || rt.TLSClientConfig.ServerName != "api.comagic.ru" || rt.TLSClientConfig.MinVersion != tls.VersionTLS12 {
+		t.Errorf("got TLS config %+v, want given one", rt.TLSClientConfig)
+	}
+	def := http.DefaultTransport.(*http.Transport)
+	if rt.MaxIdleConns != def.MaxIdleConns || rt.IdleConnTimeout != def.IdleConnTimeout || rt.Proxy == nil {
+		t.Error("default transport settings are lost")
+	}
+	if tr.transport() != rt {
+		t.Error("transport is built more than once")
+	}
+}
+
+func TestTLSConfigServer(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"success":true,"data":{"session_key":"key"}}`))
+	}))
+	defer srv.Close()
+	base, _ := url.Parse(srv.URL)
+
+	tr := New("login", "password", WithBaseURL(base)).Transport.(*Transport)
+	if err := tr.Authenticate(context.Background()); err == nil {
+		t.Fatal("got no error of unknown server certificate")
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(srv.Certificate())
+	tr = New("login", "password", WithBaseURL(base), WithTLSConfig(&tls.Config{RootCAs: roots})).Transport.(*Transport)
+	if err := tr.Authenticate(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestTLSConfigWithTransport(t *testing.T) {
+	tr := New("login", "password", WithTLSConfig(&tls.Config{}), WithTransport(http.DefaultTransport)).Transport.(*Transport)
+	err := tr.Authenticate(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "TLS config") {
+		t.Fatalf("got error %v, want custom transport and TLS config conflict", err)
+	}
+}
+
+func TestConnectionPool(t *testing.T) {
+	tr := New("login", "password", WithConnectionPool(200, 50, time.Minute)).Transport.(*Transport)
+	rt, ok := tr.transport().(*http.Transport)
+	if !ok || rt == http.DefaultTransport {
+		t.Fatalf("got transport %T, want clone of default transport", tr.transport())
+	}
+	if rt.MaxIdleConns != 200 || rt.MaxIdleConnsPerHost != 50 || rt.IdleConnTimeout != time.Minute {
+		t.Errorf("got pool %d, %d per host and %s timeout, want 200, 50 and 1m",
+			rt.MaxIdleConns, rt.MaxIdleConnsPerHost, rt.IdleConnTimeout)
+	}
+	if rt.Proxy == nil || rt.TLSHandshakeTimeout != http.DefaultTransport.(*http.Transport).TLSHandshakeTimeout {
+		t.Error("default transport settings are lost")
+	}
+
+	def := http.DefaultTransport.(*http.Transport)
+	tr = New("login", "password", WithConnectionPool(0, 10, 0)).Transport.(*Transport)
+	rt = tr.transport().(*http.Transport)
+	if rt.MaxIdleConns != def.MaxIdleConns || rt.MaxIdleConnsPerHost != 10 || rt.IdleConnTimeout != def.IdleConnTimeout {
+		t.Errorf("got pool %d, %d per host and %s timeout, want defaults kept for non positive values",
+			rt.MaxIdleConns, rt.MaxIdleConnsPerHost, rt.IdleConnTimeout)
+	}
+}
+
+func TestConnectionPoolWithTransport(t *testing.T) {
+	tr := New("login", "password", WithConnectionPool(200, 50, 0), WithTransport(http.DefaultTransport)).Transport.(*Transport)
+	err := tr.Authenticate(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "connection pool") {
+		t.Fatalf("got error %v, want custom transport and connection pool conflict", err)
+	}
+}
+
+func TestProxy(t *testing.T) {
+	var mu sync.Mutex
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		proxied = append(proxied, r.URL.Host+r.URL.Path)
+		mu.Unlock()
+		if r.URL.Path == DefaultLoginPath {
+			w.Write([]byte(`{"success":true,"data":{"session_key":"key"}}`))
+			return
+		}
+		w.Write([]byte(`{"success":true,"data":[]}`))
+	}))
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+	base, _ := url.Parse("http://api.comagic.invalid")
+
+	c := New("login", "password", WithBaseURL(base), WithProxy(proxyURL))
+	req, _ := http.NewRequest(http.MethodGet, "/api/x/", nil)
+	res, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	res.Body.Close()
+	want := "[api.comagic.invalid" + DefaultLoginPath + " api.comagic.invalid/api/x/]"
+	if got := fmt.Sprint(proxied); got != want {
+		t.Errorf("got proxied requests %s, want %s", got, want)
+	}
+}
+
+func TestProxyWithTransport(t *testing.T) {
+	proxy, _ := url.Parse("http://proxy.local:3128")
+	tr := New("login", "password", WithProxy(proxy), WithTransport(http.DefaultTransport)).Transport.(*Transport)
+	err := tr.Authenticate(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "proxy") {
+		t.Fatalf("got error %v, want custom transport and proxy conflict", err)
+	}
+}
@@ -0,0 +1,72 @@
+package comagic
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+)
+
+// CorrelationHeader is a header carrying correlation id of the request
+const CorrelationHeader = "X-Correlation-Id"
+
+// correlationKey is a context key of correlation id
+type correlationKey struct{}
+
+// WithCorrelationID returns context making requests sent with it use given
+// correlation id instead of generated one, so several requests of a single
+// operation could be correlated
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationKey{}, id)
+}
+
+// CorrelationIDFromContext returns correlation id of the request sent with
+// given context or empty string if there is none. Transport with logger,
+// tracer or correlation header enabled generates id for every request unless
+// context already has one, id is the same for all
+// retries, re-authorization replays and failover attempts of the request
+// and is attached to log records and spans made for it
+func CorrelationIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(correlationKey{}).(string)
+	return id
+}
+
+// WithCorrelationHeader is an option function enabling sending of
+// correlation id in X-Correlation-Id header of requests without one
+func WithCorrelationHeader(enabled bool) func(*Transport) {
+	return func(t *Transport) { t.correlationHeader = enabled }
+}
+
+// withCorrelation returns context with a new random correlation id unless
+// context already has one or there is nothing to use it for
+func (t *Transport) withCorrelation(ctx context.Context) context.Context {
+	if t.logger == nil && t.tracer == nil && !t.correlationHeader {
+		return ctx
+	}
+	if len(CorrelationIDFromContext(ctx)) > 0 {
+		return ctx
+	}
+	id, err := newUUID()
+	if err != nil {
+		// id is only used for diagnostics and should not fail request
+		return ctx
+	}
+	return WithCorrelationID(ctx, id)
+}
+
+// setCorrelationHeader attaches correlation id of request context to the request
+// unless it already has correlation header
+func setCorrelationHeader(r *http.Request) {
+	id := CorrelationIDFromContext(r.Context())
+	if len(id) > 0 && len(r.Header.Get(CorrelationHeader)) == 0 {
+		r.Header.Set(CorrelationHeader, id)
+	}
+}
+
+// log writes log record adding correlation id of the context to it,
+// logger must be set
+func (t *Transport) log(ctx context.Context, level slog.Level, msg string, attrs ...slog.Attr) {
+	if id := CorrelationIDFromContext(ctx); len(id) > 0 {
+		attrs = append(attrs, slog.String("correlation_id", id))
+	}
+	t.logger.LogAttrs(ctx, level, msg, attrs...)
+}
@@ -0,0 +1,106 @@
+package comagic
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCorrelationID(t *testing.T) {
+	var requests atomic.Int32
+	stub := sequenceStub(&requests, nil, http.StatusServiceUnavailable, http.StatusOK)
+	var ids []string
+	l, records := recordLogs(t)
+	tracer := &recordingTracer{}
+	c := New("login", "password", WithLogger(l), WithTracer(tracer), WithCorrelationHeader(true),
+		WithRetry(RetryPolicy{MaxAttempts: 2}),
+		WithTransport(roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			if r.URL.Path == "/api/x/" {
+				ids = append(ids, r.Header.Get(CorrelationHeader))
+			}
+			return stub(r)
+		})))
+	req, _ := http.NewRequest(http.MethodGet, "/api/x/", nil)
+	res, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	res.Body.Close()
+
+	if len(ids) != 2 || len(ids[0]) == 0 || ids[0] != ids[1] {
+		t.Fatalf("got correlation ids %q of retried request, want the same one", ids)
+	}
+	id := ids[0]
+	if got := CorrelationIDFromContext(res.Request.Context()); got != id {
+		t.Errorf("got correlation id %q of response request, want %q", got, id)
+	}
+	events := map[string]bool{}
+	for _, rec := range records() {
+		if rec["correlation_id"] != id {
+			t.Errorf("got log record %v without correlation id %q", rec, id)
+		}
+		if ev, ok := rec["event"].(string); ok {
+			events[ev] = true
+		}
+	}
+	if !events["auth"] || !events["retry"] {
+		t.Errorf("got events %v, want auth and retry", events)
+	}
+	spans := tracer.named("comagic.request")
+	if len(spans) != 1 || spans[0].attrs["comagic.correlation_id"] != id {
+		t.Errorf("got request spans %v, want one with correlation id", spans)
+	}
+
+	// id of the context is used as is and request header set by caller is kept
+	ids = nil
+	req, _ = http.NewRequestWithContext(WithCorrelationID(context.Background(), "operation-1"), http.MethodGet, "/api/x/", nil)
+	if res, err = c.Do(req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	res.Body.Close()
+	req, _ = http.NewRequest(http.MethodGet, "/api/x/", nil)
+	req.Header.Set(CorrelationHeader, "caller-1")
+	if res, err = c.Do(req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	res.Body.Close()
+	if len(ids) != 2 || ids[0] != "operation-1" || ids[1] != "caller-1" {
+		t.Errorf("got correlation ids %q, want operation-1 and caller-1", ids)
+	}
+}
+
+func TestCorrelationHeaderDisabled(t *testing.T) {
+	var logins atomic.Int32
+	stub := loginStub(&logins, 0)
+	l, _ := recordLogs(t)
+	c := New("login", "password", WithLogger(l), WithTransport(roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if id := r.Header.Get(CorrelationHeader); len(id) > 0 {
+			t.Errorf("got correlation header %q, want none by default", id)
+		}
+		return stub(r)
+	})))
+	req, _ := http.NewRequest(http.MethodGet, "/api/x/", nil)
+	res, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	res.Body.Close()
+	if len(CorrelationIDFromContext(res.Request.Context())) == 0 {
+		t.Error("correlation id is not generated without header")
+	}
+}
+
+func TestCorrelationIDUnused(t *testing.T) {
+	var logins atomic.Int32
+	c := New("login", "password", WithTransport(loginStub(&logins, 0)))
+	req, _ := http.NewRequest(http.MethodGet, "/api/x/", nil)
+	res, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	res.Body.Close()
+	if id := CorrelationIDFromContext(res.Request.Context()); len(id) > 0 {
+		t.Errorf("got correlation id %q, want none without logger, tracer and header", id)
+	}
+}
@@ -0,0 +1,100 @@
+package comagic
+
+import (
+	"context"
+	"sync"
+)
+
+// credentialsKey is a context key of request credentials
+type credentialsKey struct{}
+
+type credentials struct {
+	login    string
+	password string
+}
+
+// WithCredentialsContext returns context making Transport authorize request
+// with given credentials instead of configured ones. Every set of credentials
+// has its own session which is kept by the transport until Close, while
+// connections are shared, so single transport could serve several accounts
+func WithCredentialsContext(ctx context.Context, login, password string) context.Context {
+	return context.WithValue(ctx, credentialsKey{}, credentials{login: login, password: password})
+}
+
+// CredentialProvider provides login and password, it allows to rotate
+// credentials without recreating transport: provider is called every time
+// transport logs in, so new credentials are used with the next session
+type CredentialProvider interface {
+	Credentials(ctx context.Context) (login, password string, err error)
+}
+
+// StaticCredentials is a provider of the same credentials
+type StaticCredentials struct {
+	Login    string
+	Password string
+}
+
+// Credentials implements CredentialProvider interface
+func (c StaticCredentials) Credentials(context.Context) (string, string, error) {
+	return c.Login, c.Password, nil
+}
+
+// WithCredentialProvider is an option function for setting provider of
+// login and password used instead of Transport.Login and Transport.Password
+func WithCredentialProvider(p CredentialProvider) func(*Transport) {
+	return func(t *Transport) { t.credentialProvider = p }
+}
+
+// credentials returns login and password for authorization request
+func (t *Transport) credentials(ctx context.Context) (string, string, error) {
+	if t.credentialProvider == nil {
+		return t.Login, t.Password, nil
+	}
+	login, password, err := t.credentialProvider.Credentials(ctx)
+	if err == nil {
+		err = ctx.Err()
+	}
+	return login, password, err
+}
+
+// tenants holds transports of credentials given with request context
+type tenants struct {
+	mu sync.Mutex
+	m  map[credentials]*Transport
+}
+
+// tenant returns transport authorizing requests with credentials of the context,
+// nil is returned if context has no credentials or they are transport ones
+func (t *Transport) tenant(ctx context.Context) *Transport {
+	c, ok := ctx.Value(credentialsKey{}).(credentials)
+	if !ok || (c.login == t.Login && c.password == t.Password && len(t.token) == 0) {
+		return nil
+	}
+	t.tenants.mu.Lock()
+	defer t.tenants.mu.Unlock()
+	if tt, ok := t.tenants.m[c]; ok {
+		return tt
+	}
+	tt := t.CloneWithCredentials(c.login, c.password)
+	tt.token = ""
+	// tenants share connection pool of the transport
+	if t.tuned() {
+		t.tuneOnce.Do(t.tune)
+		tt.tuneOnce.Do(func() { tt.tunedTransport = t.tunedTransport })
+	}
+	if t.tenants.m == nil {
+		t.tenants.m = map[credentials]*Transport{}
+	}
+	t.tenants.m[c] = tt
+	return tt
+}
+
+// closeTenants stops transports of context credentials
+func (t *Transport) closeTenants() {
+	t.tenants.mu.Lock()
+	defer t.tenants.mu.Unlock()
+	for _, tt := range t.tenants.m {
+		tt.Close()
+	}
+	t.tenants.m = nil
+}
@@ -0,0 +1,147 @@
+package comagic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCredentialsContext(t *testing.T) {
+	var mu sync.Mutex
+	logins := map[string]int{}
+	stub := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Path == DefaultLoginPath {
+			if err := r.ParseMultipartForm(1 << 10); err != nil {
+				t.Errorf("invalid login request: %v", err)
+			}
+			login := r.FormValue("login")
+			mu.Lock()
+			logins[login]++
+			mu.Unlock()
+			return stubResponse(r, http.StatusOK, `{"success":true,"data":{"session_key":"key-`+login+`"}}`), nil
+		}
+		return stubResponse(r, http.StatusOK, `{"success":true,"data":[]}`), nil
+	})
+	c := New("default", "password", WithTransport(stub))
+	get := func(ctx context.Context) string {
+		t.Helper()
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "/api/x/", nil)
+		res, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		res.Body.Close()
+		return res.Request.URL.Query().Get("session_key")
+	}
+	first := WithCredentialsContext(context.Background(), "first", "password")
+	second := WithCredentialsContext(context.Background(), "second", "password")
+	for i := 0; i < 2; i++ {
+		if key := get(first); key != "key-first" {
+			t.Errorf("got session key %q of first account, want key-first", key)
+		}
+		if key := get(second); key != "key-second" {
+			t.Errorf("got session key %q of second account, want key-second", key)
+		}
+		if key := get(context.Background()); key != "key-default" {
+			t.Errorf("got session key %q without context credentials, want key-default", key)
+		}
+	}
+	for _, login := range []string{"first", "second", "default"} {
+		if n := logins[login]; n != 1 {
+			t.Errorf("got %d logins of %s, want session reused", n, login)
+		}
+	}
+	if err := c.Transport.(*Transport).Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestCredentialsContextConnectionPool(t *testing.T) {
+	tr := New("default", "password", WithConnectionPool(100, 10, time.Minute)).Transport.(*Transport)
+	defer tr.Close()
+	for _, login := range []string{"first", "second"} {
+		tt := tr.tenant(WithCredentialsContext(context.Background(), login, "password"))
+		if tt == nil {
+			t.Fatalf("got no transport of %s account", login)
+		}
+		if tt.transport() != tr.transport() {
+			t.Errorf("got own connection pool of %s account, want transport one shared", login)
+		}
+	}
+}
+
+// rotatingCredentials returns password-<n> on n-th call
+type rotatingCredentials struct {
+	calls atomic.Int32
+	err   error
+}
+
+func (p *rotatingCredentials) Credentials(ctx context.Context) (string, string, error) {
+	n := p.calls.Add(1)
+	return "login", fmt.Sprintf("password-%d", n), p.err
+}
+
+func TestCredentialProvider(t *testing.T) {
+	var passwords []string
+	stub := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Path == DefaultLoginPath {
+			if err := r.ParseMultipartForm(1 << 10); err != nil {
+				t.Errorf("invalid login request: %v", err)
+			}
+			passwords = append(passwords, r.FormValue("password"))
+			return stubResponse(r, http.StatusOK, `{"success":true,"data":{"session_key":"key"}}`), nil
+		}
+		return stubResponse(r, http.StatusOK, `{"success":true,"data":[]}`), nil
+	})
+	p := &rotatingCredentials{}
+	tr := New("", "", WithTransport(stub), WithCredentialProvider(p)).Transport.(*Transport)
+	ctx := context.Background()
+	if err := tr.Authenticate(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := tr.Authenticate(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := tr.RefreshSession(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := fmt.Sprint(passwords); got != "[password-1 password-2]" {
+		t.Errorf("got login passwords %s, want rotated password used by the next login", got)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := tr.RefreshSession(cancelled); !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v, want context error", err)
+	}
+}
+
+func TestCredentialProviderError(t *testing.T) {
+	errVault := errors.New("vault is sealed")
+	logins := 0
+	stub := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		logins++
+		return stubResponse(r, http.StatusOK, `{"success":true,"data":{"session_key":"key"}}`), nil
+	})
+	tr := New("", "", WithTransport(stub), WithCredentialProvider(&rotatingCredentials{err: errVault})).Transport.(*Transport)
+	err := tr.Authenticate(context.Background())
+	ae := &AuthError{}
+	if !errors.As(err, &ae) || !errors.Is(err, errVault) {
+		t.Fatalf("got error %v, want AuthError with provider error", err)
+	}
+	if logins != 0 {
+		t.Errorf("got %d login requests, want none without credentials", logins)
+	}
+}
+
+func TestStaticCredentials(t *testing.T) {
+	login, password, err := StaticCredentials{Login: "login", Password: "password"}.Credentials(context.Background())
+	if err != nil || login != "login" || password != "password" {
+		t.Fatalf("got %q, %q and error %v, want static credentials", login, password, err)
+	}
+}
@@ -0,0 +1,210 @@
+package comagic
+
+import (
+	"context"
+	"log/slog"
+	"net/url"
+	"strconv"
+)
+
+// DefaultPageSize is a number of items requested per page when none is set
+const DefaultPageSize = 1000
+
+// MaxPageSize is a maximum number of items API returns per request,
+// larger limits are silently truncated by API
+const MaxPageSize = 10000
+
+// PageFunc fetches page of at most limit items starting at offset,
+// it returns items and total number of items or negative number if total is unknown
+type PageFunc[T any] func(ctx context.Context, offset, limit int) ([]T, int, error)
+
+// PageRequest is a position of requested page. Token is a page token returned
+// with the previous page, it is empty for the first page and for APIs
+// paginating with offset
+type PageRequest struct {
+	Offset int
+	Limit  int
+	Token  string
+}
+
+// Page is a page of items
+type Page[T any] struct {
+	Items []T
+	// Total is a total number of items or negative number if it is unknown
+	Total int
+	// NextToken is a token of the next page, empty if API paginates
+	// with offset or there are no more pages
+	NextToken string
+}
+
+// PagerFunc fetches requested page, it could paginate either with
+// offset or with page tokens
+type PagerFunc[T any] func(ctx context.Context, req PageRequest) (Page[T], error)
+
+// Cursor iterates over paginated API results issuing page requests as needed.
+//
+//	cur := NewCursor(ctx, 100, fetch)
+//	for cur.Next() {
+//		item := cur.Value()
+//	}
+//	if err := cur.Err(); err != nil {
+//	}
+type Cursor[T any] struct {
+	ctx   context.Context
+	fetch PagerFunc[T]
+	limit int
+
+	offset int
+	token  string
+	page   []T
+	pos    int
+	last   bool
+	err    error
+}
+
+// NewCursor returns cursor fetching pages of given size,
+// non positive size means DefaultPageSize and size above
+// MaxPageSize is capped to it
+func NewCursor[T any](ctx context.Context, size int, fetch PageFunc[T]) *Cursor[T] {
+	return NewPageCursor(ctx, size, func(ctx context.Context, req PageRequest) (Page[T], error) {
+		items, total, err := fetch(ctx, req.Offset, req.Limit)
+		return Page[T]{Items: items, Total: total}, err
+	})
+}
+
+// NewPageCursor is like NewCursor but pages could be fetched either with offset
+// or with page tokens: once page has next token it is used for the next page
+// and iteration ends on page without it
+func NewPageCursor[T any](ctx context.Context, size int, fetch PagerFunc[T]) *Cursor[T] {
+	if size <= 0 {
+		size = DefaultPageSize
+	}
+	if size > MaxPageSize {
+		size = MaxPageSize
+	}
+	return &Cursor[T]{ctx: ctx, fetch: fetch, limit: size}
+}
+
+// Next advances cursor to the next item, it returns false when there are
+// no more items or an error occurred
+func (c *Cursor[T]) Next() bool {
+	if c.err != nil {
+		return false
+	}
+	if c.pos+1 < len(c.page) {
+		c.pos++
+		return true
+	}
+	if c.last {
+		return false
+	}
+	if err := c.ctx.Err(); err != nil {
+		c.err = err
+		return false
+	}
+	page, err := c.fetch(c.ctx, PageRequest{Offset: c.offset, Limit: c.limit, Token: c.token})
+	if err != nil {
+		c.err = err
+		return false
+	}
+	c.offset += len(page.Items)
+	switch {
+	case len(page.NextToken) > 0:
+		c.token = page.NextToken
+		c.last = len(page.Items) == 0
+	case len(c.token) > 0:
+		// token paginated API has no more pages
+		c.last = true
+	default:
+		c.last = len(page.Items) < c.limit
+	}
+	if page.Total >= 0 && c.offset >= page.Total {
+		c.last = true
+	}
+	c.page, c.pos = page.Items, 0
+	return len(page.Items) > 0
+}
+
+// Value returns current item
+func (c *Cursor[T]) Value() T {
+	var v T
+	if c.pos < len(c.page) {
+		v = c.page[c.pos]
+	}
+	return v
+}
+
+// Err returns the first error occurred during iteration
+func (c *Cursor[T]) Err() error {
+	return c.err
+}
+
+// All drains cursor and returns all items
+func (c *Cursor[T]) All() ([]T, error) {
+	items := []T{}
+	for c.Next() {
+		items = append(items, c.Value())
+	}
+	return items, c.Err()
+}
+
+// getPaged makes GET request to report endpoint for up to limit items starting
+// at offset decoding them into slice. Limit above MaxPageSize is fetched page by
+// page of MaxPageSize with warning logged, so no items are truncated by API
+func getPaged[T any](ctx context.Context, c *Client, path string, query func(offset, limit int) url.Values, offset, limit int) ([]T, error) {
+	if limit <= MaxPageSize {
+		items := []T{}
+		if err := c.GetJSON(ctx, path, query(offset, limit), &items); err != nil {
+			return nil, err
+		}
+		return items, nil
+	}
+	if l := c.logger(); l != nil {
+		l.LogAttrs(ctx, slog.LevelWarn, "limit exceeds max page size",
+			slog.String("event", "limit_capped"), slog.String("path", path),
+			slog.Int("limit", limit), slog.Int("max", MaxPageSize))
+	}
+	fetch := pager[T](c, path, func(req PageRequest) url.Values {
+		return query(offset+req.Offset, min(req.Limit, limit-req.Offset))
+	}, false)
+	return NewPageCursor(ctx, MaxPageSize, func(ctx context.Context, req PageRequest) (Page[T], error) {
+		page, err := fetch(ctx, req)
+		page.Total = limit
+		return page, err
+	}).All()
+}
+
+// pager returns page function of API endpoint, query of the page is built
+// by query function and page token is added to it when API returned one.
+// Pages of reference endpoints are served from cache if it is enabled
+func pager[T any](c *Client, path string, query func(req PageRequest) url.Values, reference bool) PagerFunc[T] {
+	return func(ctx context.Context, req PageRequest) (Page[T], error) {
+		v := query(req)
+		if len(req.Token) > 0 {
+			v.Set("page_token", req.Token)
+		}
+		var env apiResp
+		var err error
+		if reference {
+			env, err = c.getReferenceEnvelope(ctx, path, v)
+		} else {
+			env, err = c.getEnvelope(ctx, path, v)
+		}
+		if err != nil {
+			return Page[T]{}, err
+		}
+		page := Page[T]{Items: []T{}, Total: -1, NextToken: env.NextPageToken}
+		if err := c.decodeData(env.Data, &page.Items); err != nil {
+			return Page[T]{}, err
+		}
+		return page, nil
+	}
+}
+
+// pageQuery returns query with offset and limit of the page
+func pageQuery(req PageRequest) url.Values {
+	v := url.Values{}
+	v.Set("offset", strconv.Itoa(req.Offset))
+	v.Set("limit", strconv.Itoa(req.Limit))
+	return v
+}
@@ -0,0 +1,223 @@
+package comagic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nk2ge5k/go-api-comagic/comagictest"
+)
+
+// pages returns page function serving total items, n-th item is n
+func pages(total int, calls *int) PageFunc[int] {
+	return func(ctx context.Context, offset, limit int) ([]int, int, error) {
+		*calls++
+		items := []int{}
+		for i := offset; i < total && i < offset+limit; i++ {
+			items = append(items, i)
+		}
+		return items, -1, nil
+	}
+}
+
+func TestCursor(t *testing.T) {
+	calls := 0
+	items, err := NewCursor(context.Background(), 10, pages(25, &calls)).All()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(items) != 25 || calls != 3 {
+		t.Fatalf("got %d items in %d pages, want 25 items in 3 pages", len(items), calls)
+	}
+	for i, v := range items {
+		if v != i {
+			t.Fatalf("got item %d at %d, want items in order", v, i)
+		}
+	}
+}
+
+func TestCursorTotal(t *testing.T) {
+	calls := 0
+	fetch := func(ctx context.Context, offset, limit int) ([]int, int, error) {
+		calls++
+		items, _, err := pages(20, new(int))(ctx, offset, limit)
+		return items, 20, err
+	}
+	items, err := NewCursor(context.Background(), 10, fetch).All()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(items) != 20 || calls != 2 {
+		t.Fatalf("got %d items in %d pages, want 20 items without extra page request", len(items), calls)
+	}
+}
+
+func TestCursorError(t *testing.T) {
+	errPage := errors.New("page failed")
+	calls := 0
+	cur := NewCursor(context.Background(), 10, func(ctx context.Context, offset, limit int) ([]int, int, error) {
+		calls++
+		if offset > 0 {
+			return nil, 0, errPage
+		}
+		return pages(25, new(int))(ctx, offset, limit)
+	})
+	n := 0
+	for cur.Next() {
+		n++
+	}
+	if n != 10 || !errors.Is(cur.Err(), errPage) {
+		t.Fatalf("got %d items and error %v, want 10 items and page error", n, cur.Err())
+	}
+	if cur.Next() || calls != 2 {
+		t.Errorf("cursor continued after error with %d page requests", calls)
+	}
+}
+
+func TestCursorContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	calls := 0
+	cur := NewCursor(ctx, 10, pages(25, &calls))
+	n := 0
+	for cur.Next() {
+		if n++; n == 5 {
+			cancel()
+		}
+	}
+	if n != 10 || !errors.Is(cur.Err(), context.Canceled) || calls != 1 {
+		t.Fatalf("got %d items in %d pages and error %v, want iteration stopped after the first page", n, calls, cur.Err())
+	}
+}
+
+func TestCursorPageSize(t *testing.T) {
+	for size, want := range map[int]int{0: DefaultPageSize, -1: DefaultPageSize, 50: 50, MaxPageSize + 1: MaxPageSize} {
+		var got int
+		NewCursor(context.Background(), size, func(ctx context.Context, offset, limit int) ([]int, int, error) {
+			got = limit
+			return nil, 0, nil
+		}).Next()
+		if got != want {
+			t.Errorf("size %d: got limit %d, want %d", size, got, want)
+		}
+	}
+}
+
+func TestCursorPageToken(t *testing.T) {
+	var tokens []string
+	cur := NewPageCursor(context.Background(), 10, func(ctx context.Context, req PageRequest) (Page[int], error) {
+		tokens = append(tokens, req.Token)
+		switch req.Token {
+		case "":
+			return Page[int]{Items: []int{1, 2}, Total: -1, NextToken: "b"}, nil
+		case "b":
+			return Page[int]{Items: []int{3}, Total: -1, NextToken: "c"}, nil
+		}
+		return Page[int]{Items: []int{4}, Total: -1}, nil
+	})
+	items, err := cur.All()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(items) != 4 || len(tokens) != 3 || tokens[1] != "b" || tokens[2] != "c" {
+		t.Fatalf("got items %v with tokens %q, want 4 items of 3 pages", items, tokens)
+	}
+}
+
+func TestCursorPageOffset(t *testing.T) {
+	var offsets []int
+	cur := NewPageCursor(context.Background(), 2, func(ctx context.Context, req PageRequest) (Page[int], error) {
+		offsets = append(offsets, req.Offset)
+		if len(req.Token) > 0 {
+			t.Errorf("got page token %q of offset paginated API", req.Token)
+		}
+		items := []int{}
+		for i := req.Offset; i < 5 && i < req.Offset+req.Limit; i++ {
+			items = append(items, i)
+		}
+		return Page[int]{Items: items, Total: -1}, nil
+	})
+	items, err := cur.All()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fmt.Sprint(items) != "[0 1 2 3 4]" || fmt.Sprint(offsets) != "[0 2 4]" {
+		t.Fatalf("got items %v with offsets %v, want 5 items of 3 pages", items, offsets)
+	}
+}
+
+func TestPagerPageToken(t *testing.T) {
+	var mu sync.Mutex
+	var queries []string
+	srv := comagictest.NewServer(t, comagictest.WithHandler("/api/employees/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		mu.Lock()
+		queries = append(queries, q.Get("offset")+" "+q.Get("page_token"))
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		if q.Get("page_token") == "next" {
+			w.Write([]byte(`{"success":true,"data":[{"id":2}]}`))
+			return
+		}
+		w.Write([]byte(`{"success":true,"next_page_token":"next","data":[{"id":1}]}`))
+	})))
+	c := NewClient(New("login", "password", WithBaseURL(srv.URL())))
+	employees, err := c.Employees(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(employees) != 2 || employees[1].ID != 2 {
+		t.Fatalf("got employees %+v, want both pages", employees)
+	}
+	if got, want := fmt.Sprint(queries), "[0  1 next]"; got != want {
+		t.Errorf("got page queries %q, want %q", got, want)
+	}
+}
+
+func TestGetPagedLimitCap(t *testing.T) {
+	var mu sync.Mutex
+	var requested []string
+	srv := comagictest.NewServer(t, comagictest.WithHandler("/api/calls_report/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		mu.Lock()
+		requested = append(requested, fmt.Sprintf("%d+%d", offset, limit))
+		mu.Unlock()
+		// endpoint has more calls than requested
+		items := make([]string, limit)
+		for i := range items {
+			items[i] = `{"id":` + strconv.Itoa(offset+i+1) + `}`
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"success":true,"data":[` + strings.Join(items, ",") + `]}`))
+	})))
+	l, records := recordLogs(t)
+	c := NewClient(New("login", "password", WithBaseURL(srv.URL()), WithLogger(l)))
+	from := time.Now().Add(-time.Hour)
+	resp, err := c.CallsReport(context.Background(), CallsReportRequest{DateFrom: from, DateTill: from.Add(time.Minute), Limit: 50000})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(resp.Calls) != 50000 || resp.Calls[0].ID != 1 || resp.Calls[49999].ID != 50000 {
+		t.Fatalf("got %d calls, want 50000 in order", len(resp.Calls))
+	}
+	want := "[0+10000 10000+10000 20000+10000 30000+10000 40000+10000]"
+	if got := fmt.Sprint(requested); got != want {
+		t.Errorf("got pages %s, want %s", got, want)
+	}
+	warned := false
+	for _, rec := range records() {
+		if rec["event"] == "limit_capped" && rec["limit"] == float64(50000) && rec["max"] == float64(MaxPageSize) {
+			warned = true
+		}
+	}
+	if !warned {
+		t.Error("capped limit is not logged")
+	}
+}
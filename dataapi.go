@@ -0,0 +1,135 @@
+package comagic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+)
+
+// DefaultDataAPIURL is a Data API endpoint used when none is set
+var DefaultDataAPIURL = &url.URL{Scheme: "https", Host: "dataapi.comagic.ru", Path: "/v2.0"}
+
+// DataAPIClient is a client of JSON-RPC 2.0 Data API authorized with access token.
+// Requests are sent with Transport so retry, logging, tracing and metrics
+// options work the same way as for the session API. Every call is a POST,
+// so retry policy only retries read-only get.* methods and calls sent
+// with idempotency key, see WithIdempotency
+type DataAPIClient struct {
+	token string
+	hc    *http.Client
+	id    uint64
+}
+
+// NewDataAPI returns Data API client authorized with given access token,
+// WithBaseURL option overrides DefaultDataAPIURL
+func NewDataAPI(token string, opts ...func(*Transport)) *DataAPIClient {
+	t := &Transport{BaseURL: DefaultDataAPIURL}
+	t.noTrailingSlash = true
+	for _, opt := range opts {
+		opt(t)
+	}
+	t.token = token
+	t.tokenInBody = true
+	return &DataAPIClient{token: token, hc: &http.Client{Transport: t, Timeout: t.clientTimeout}}
+}
+
+// RPCError is an error member of JSON-RPC response
+type RPCError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("rpc error: %d %s", e.Code, e.Message)
+}
+
+// Call calls Data API method with given params and decodes call result into result.
+// Params must encode into JSON object or be nil, access_token is added to it.
+// Error reported by API is returned as *RPCError.
+// Methods with get. prefix are read-only and retried like GET requests
+func (c *DataAPIClient) Call(ctx context.Context, method string, params, result interface{}) error {
+	p, err := c.params(params)
+	if err != nil {
+		return fmt.Errorf("call %s: could not encode params: %v", method, err)
+	}
+	id := atomic.AddUint64(&c.id, 1)
+	body, err := json.Marshal(rpcRequest{JSONRPC: "2.0", ID: id, Method: method, Params: p})
+	if err != nil {
+		return fmt.Errorf("call %s: could not encode request: %v", method, err)
+	}
+	if strings.HasPrefix(method, "get.") {
+		ctx = withReadOnly(ctx)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("call %s: could not create request: %v", method, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := c.hc.Do(req)
+	if err != nil {
+		return fmt.Errorf("call %s: %w", method, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("call %s: invalid response: %d %s", method, res.StatusCode, http.StatusText(res.StatusCode))
+	}
+	rr := rpcResponse{}
+	if err := json.NewDecoder(res.Body).Decode(&rr); err != nil {
+		return fmt.Errorf("call %s: could not decode response: %v", method, err)
+	}
+	if rr.Error != nil {
+		return fmt.Errorf("call %s: %w", method, rr.Error)
+	}
+	if result == nil || len(rr.Result) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(rr.Result, result); err != nil {
+		return fmt.Errorf("call %s: could not decode result: %v", method, err)
+	}
+	return nil
+}
+
+// params returns call params with access token injected
+func (c *DataAPIClient) params(params interface{}) (map[string]json.RawMessage, error) {
+	p := map[string]json.RawMessage{}
+	if params != nil {
+		b, err := json.Marshal(params)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(b, &p); err != nil {
+			return nil, err
+		}
+	}
+	if p == nil {
+		// params encoded into null
+		p = map[string]json.RawMessage{}
+	}
+	token, err := json.Marshal(c.token)
+	if err != nil {
+		return nil, err
+	}
+	p["access_token"] = token
+	return p, nil
+}
+
+type rpcRequest struct {
+	JSONRPC string                     `json:"jsonrpc"`
+	ID      uint64                     `json:"id"`
+	Method  string                     `json:"method"`
+	Params  map[string]json.RawMessage `json:"params"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      uint64          `json:"id"`
+	Result  json.RawMessage `json:"result"`
+	Error   *RPCError       `json:"error"`
+}
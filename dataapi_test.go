@@ -0,0 +1,134 @@
+package comagic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+)
+
+// rpcServer returns stub RPC server answering with result of given function
+// or with RPC error it returns
+func rpcServer(t *testing.T, fn func(req map[string]interface{}) (interface{}, *RPCError)) *DataAPIClient {
+	t.Helper()
+	base := newLoginServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v2.0" {
+			t.Errorf("got %s %s, want POST /v2.0", r.Method, r.URL.Path)
+		}
+		req := map[string]interface{}{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("invalid request: %v", err)
+		}
+		result, rerr := fn(req)
+		res := map[string]interface{}{"jsonrpc": "2.0", "id": req["id"]}
+		if rerr != nil {
+			res["error"] = rerr
+		} else {
+			res["result"] = result
+		}
+		json.NewEncoder(w).Encode(res)
+	})
+	return NewDataAPI("token", WithBaseURL(base.JoinPath("/v2.0")))
+}
+
+func TestDataAPICall(t *testing.T) {
+	var got []map[string]interface{}
+	c := rpcServer(t, func(req map[string]interface{}) (interface{}, *RPCError) {
+		got = append(got, req)
+		return map[string]interface{}{"data": []map[string]int{{"id": 1}}}, nil
+	})
+	result := struct {
+		Data []struct {
+			ID int `json:"id"`
+		} `json:"data"`
+	}{}
+	params := map[string]interface{}{"date_from": "2024-01-01 00:00:00"}
+	for i := 0; i < 2; i++ {
+		if err := c.Call(context.Background(), "get.calls_report", params, &result); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if len(result.Data) != 1 || result.Data[0].ID != 1 {
+		t.Errorf("got result %+v, want decoded call", result)
+	}
+	req := got[0]
+	if req["jsonrpc"] != "2.0" || req["method"] != "get.calls_report" {
+		t.Errorf("got request %v, want JSON-RPC 2.0 get.calls_report", req)
+	}
+	p, _ := req["params"].(map[string]interface{})
+	if p["access_token"] != "token" || p["date_from"] != "2024-01-01 00:00:00" {
+		t.Errorf("got params %v, want given params with access token", p)
+	}
+	if got[0]["id"] == got[1]["id"] {
+		t.Errorf("got same id %v of different calls", got[0]["id"])
+	}
+	if _, ok := params["access_token"]; ok {
+		t.Error("access token is added to params of the caller")
+	}
+}
+
+func TestDataAPINilParams(t *testing.T) {
+	c := rpcServer(t, func(req map[string]interface{}) (interface{}, *RPCError) {
+		p, _ := req["params"].(map[string]interface{})
+		if len(p) != 1 || p["access_token"] != "token" {
+			t.Errorf("got params %v, want only access token", p)
+		}
+		return nil, nil
+	})
+	if err := c.Call(context.Background(), "get.account", nil, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestDataAPIError(t *testing.T) {
+	c := rpcServer(t, func(req map[string]interface{}) (interface{}, *RPCError) {
+		return nil, &RPCError{Code: -32602, Message: "Invalid params"}
+	})
+	err := c.Call(context.Background(), "get.calls_report", nil, nil)
+	re := &RPCError{}
+	if !errors.As(err, &re) || re.Code != -32602 || re.Message != "Invalid params" {
+		t.Fatalf("got error %v, want RPCError -32602", err)
+	}
+}
+
+func TestDataAPIParamsNotObject(t *testing.T) {
+	c := NewDataAPI("token", WithTransport(roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		t.Fatal("request with invalid params is sent")
+		return nil, nil
+	})))
+	if err := c.Call(context.Background(), "get.calls_report", []int{1}, nil); err == nil {
+		t.Fatal("got no error of params not encoded into object")
+	}
+}
+
+func TestDataAPIRetry(t *testing.T) {
+	for _, tc := range []struct {
+		method string
+		sends  int32
+	}{
+		{method: "get.calls_report", sends: 2},
+		{method: "set.call_tags", sends: 1},
+	} {
+		var sends atomic.Int32
+		base := newLoginServer(t, func(w http.ResponseWriter, r *http.Request) {
+			if sends.Add(1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{}}`))
+		})
+		c := NewDataAPI("token", WithBaseURL(base.JoinPath("/v2.0")), WithRetry(RetryPolicy{MaxAttempts: 2}))
+		err := c.Call(context.Background(), tc.method, nil, nil)
+		if n := sends.Load(); n != tc.sends {
+			t.Errorf("%s: got %d sends, want %d", tc.method, n, tc.sends)
+		}
+		if tc.sends == 2 && err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.method, err)
+		}
+		if tc.sends == 1 && err == nil {
+			t.Errorf("%s: got no error of not retried 503 response", tc.method)
+		}
+	}
+}
@@ -0,0 +1,12 @@
+// Package comagic provides http.RoundTripper authorizing requests to
+// comagic API with a session key and Client of the API data endpoints.
+//
+// Transport could be instrumented with Metrics given to WithMetrics.
+// Package has no dependencies, so it has no ready-made Prometheus
+// collector: Metrics implementation incrementing prometheus.CounterVec
+// and observing prometheus.HistogramVec is a few lines long.
+//
+// By the same reason tracing is configured with WithTracer taking Tracer
+// interface instead of OpenTelemetry trace.TracerProvider. Tracer mirrors
+// OpenTelemetry tracer API, so adapter of it is a few lines long as well.
+package comagic
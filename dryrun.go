@@ -0,0 +1,50 @@
+package comagic
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+)
+
+// dryRunKey is a session key used in dry run mode
+const dryRunKey = "dry-run"
+
+// dryRunBody is a response body returned in dry run mode
+const dryRunBody = `{"success":true,"data":null}`
+
+// WithDryRun is an option function for switching dry run mode. In dry run mode
+// requests are built as usual but never sent: authorization is skipped,
+// request is logged with configured logger and successful response with
+// empty data is returned
+func WithDryRun(enabled bool) func(*Transport) {
+	return func(t *Transport) { t.dryRun = enabled }
+}
+
+// dryRoundTrip logs request and returns canned response without sending it
+func (t *Transport) dryRoundTrip(r *http.Request) (*http.Response, error) {
+	t.authorize(r, dryRunKey)
+	var size int64
+	if hasBody(r) {
+		size, _ = io.Copy(io.Discard, r.Body)
+		r.Body.Close()
+	}
+	if t.logger != nil {
+		t.log(r.Context(), slog.LevelInfo, "dry run request",
+			slog.String("event", "dry_run"),
+			slog.String("method", r.Method),
+			slog.String("url", t.redactURL(r.URL)),
+			slog.Int64("body_size", size))
+	}
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader([]byte(dryRunBody))),
+		ContentLength: int64(len(dryRunBody)),
+		Request:       r,
+	}, nil
+}
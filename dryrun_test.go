@@ -0,0 +1,56 @@
+package comagic
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDryRun(t *testing.T) {
+	stub := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		t.Errorf("request %s is sent in dry run mode", r.URL)
+		return nil, http.ErrHandlerTimeout
+	})
+	l, records := recordLogs(t)
+	c := New("login", "password", WithTransport(stub), WithDryRun(true), WithLogger(l))
+	req, _ := http.NewRequest(http.MethodPost, "/api/call_request?site_id=1", strings.NewReader("payload"))
+	res, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("got status %d, want 200", res.StatusCode)
+	}
+
+	var rec map[string]interface{}
+	for _, r := range records() {
+		if r["event"] == "dry_run" {
+			rec = r
+		}
+	}
+	if rec == nil {
+		t.Fatal("dry run request is not logged")
+	}
+	want := "https://api.comagic.ru/api/call_request/?site_id=1&session_key=***"
+	if rec["method"] != http.MethodPost || rec["url"] != want || rec["body_size"] != float64(len("payload")) {
+		t.Errorf("got record %v, want method, redacted url %q and body size", rec, want)
+	}
+}
+
+func TestDryRunClient(t *testing.T) {
+	stub := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		t.Errorf("request %s is sent in dry run mode", r.URL)
+		return nil, http.ErrHandlerTimeout
+	})
+	c := NewClient(New("login", "password", WithTransport(stub), WithDryRun(true)))
+	resp, err := c.CallsReport(context.Background(), CallsReportRequest{DateFrom: time.Now().Add(-time.Hour), DateTill: time.Now()})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Calls == nil || len(resp.Calls) != 0 {
+		t.Errorf("got calls %v, want empty report", resp.Calls)
+	}
+}
@@ -0,0 +1,49 @@
+package comagic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Employee statuses
+const (
+	EmployeeActive   = "active"
+	EmployeeInactive = "inactive"
+)
+
+// Employee is an employee (operator) of the account
+type Employee struct {
+	ID        int64  `json:"id"`
+	Name      string `json:"name"`
+	Extension string `json:"extension_phone_number"`
+	Status    string `json:"status"`
+	// Extra holds response fields unknown to the package
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler interface
+func (e *Employee) UnmarshalJSON(b []byte) error {
+	type plain Employee
+	if err := json.Unmarshal(b, (*plain)(e)); err != nil {
+		return err
+	}
+	extra, err := unknownFields(b, e)
+	e.Extra = extra
+	return err
+}
+
+// Active reports whether employee is active
+func (e Employee) Active() bool {
+	return e.Status == EmployeeActive
+}
+
+// Employees returns employees of the account including inactive ones,
+// following pages if API splits the list
+func (c *Client) Employees(ctx context.Context) ([]Employee, error) {
+	employees, err := NewPageCursor(ctx, DefaultPageSize, pager[Employee](c, "/api/employees/", pageQuery, true)).All()
+	if err != nil {
+		return nil, fmt.Errorf("employees: %w", err)
+	}
+	return employees, nil
+}
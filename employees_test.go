@@ -0,0 +1,57 @@
+package comagic
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/nk2ge5k/go-api-comagic/comagictest"
+)
+
+func TestEmployees(t *testing.T) {
+	const total = DefaultPageSize + 2
+	srv := comagictest.NewServer(t, comagictest.WithHandler("/api/employees/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		page := []map[string]interface{}{}
+		for id := offset + 1; id <= total && id <= offset+limit; id++ {
+			status := EmployeeActive
+			if id == total {
+				status = EmployeeInactive
+			}
+			page = append(page, map[string]interface{}{
+				"id": id, "name": "Employee " + strconv.Itoa(id),
+				"extension_phone_number": strconv.Itoa(100 + id), "status": status,
+			})
+		}
+		comagictest.Respond(w, page)
+	})))
+	c := NewClient(New("login", "password", WithBaseURL(srv.URL())))
+	employees, err := c.Employees(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(employees) != total {
+		t.Fatalf("got %d employees, want %d of both pages", len(employees), total)
+	}
+	if e := employees[0]; e.ID != 1 || e.Name != "Employee 1" || e.Extension != "101" || !e.Active() {
+		t.Errorf("got employee %+v, want active Employee 1", e)
+	}
+	if e := employees[total-1]; e.ID != total || e.Active() || e.Status != EmployeeInactive {
+		t.Errorf("got employee %+v, want inactive one kept", e)
+	}
+}
+
+func TestEmployeesAPIError(t *testing.T) {
+	srv := comagictest.NewServer(t, comagictest.WithHandler("/api/employees/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		comagictest.RespondError(w, "access_denied", "no access to employees")
+	})))
+	c := NewClient(New("login", "password", WithBaseURL(srv.URL())))
+	_, err := c.Employees(context.Background())
+	ae := &APIError{}
+	if !errors.As(err, &ae) || ae.Code != "access_denied" {
+		t.Fatalf("got error %v, want access_denied APIError", err)
+	}
+}
@@ -0,0 +1,44 @@
+package comagic
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+// Environment variables read by NewFromEnv
+const (
+	EnvLogin    = "COMAGIC_LOGIN"
+	EnvPassword = "COMAGIC_PASSWORD"
+	EnvBaseURL  = "COMAGIC_BASE_URL"
+	EnvToken    = "COMAGIC_TOKEN"
+)
+
+// NewFromEnv returns comagic API client configured from environment variables.
+// Either COMAGIC_TOKEN or both COMAGIC_LOGIN and COMAGIC_PASSWORD must be set,
+// COMAGIC_BASE_URL is optional. Given options are applied after environment ones
+func NewFromEnv(opts ...func(*Transport)) (*http.Client, error) {
+	login, password, token := os.Getenv(EnvLogin), os.Getenv(EnvPassword), os.Getenv(EnvToken)
+
+	var envOpts []func(*Transport)
+	switch {
+	case len(token) > 0 && (len(login) > 0 || len(password) > 0):
+		return nil, fmt.Errorf("new from env: %s could not be combined with %s and %s", EnvToken, EnvLogin, EnvPassword)
+	case len(token) > 0:
+		envOpts = append(envOpts, WithToken(token))
+	case len(login) == 0 || len(password) == 0:
+		return nil, fmt.Errorf("new from env: %s and %s or %s must be set", EnvLogin, EnvPassword, EnvToken)
+	}
+	if raw := os.Getenv(EnvBaseURL); len(raw) > 0 {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("new from env: invalid %s: %v", EnvBaseURL, err)
+		}
+		if err := validateBaseURL(u); err != nil {
+			return nil, fmt.Errorf("new from env: invalid %s: %v", EnvBaseURL, err)
+		}
+		envOpts = append(envOpts, WithBaseURL(u))
+	}
+	return New(login, password, append(envOpts, opts...)...), nil
+}
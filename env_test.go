@@ -0,0 +1,69 @@
+package comagic
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewFromEnv(t *testing.T) {
+	t.Setenv(EnvLogin, "login")
+	t.Setenv(EnvPassword, "password")
+	t.Setenv(EnvToken, "")
+	t.Setenv(EnvBaseURL, "https://proxy.example.com/comagic")
+	c, err := NewFromEnv()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tr := c.Transport.(*Transport)
+	if tr.Login != "login" || tr.Password != "password" {
+		t.Errorf("got credentials %q and %q, want ones from environment", tr.Login, tr.Password)
+	}
+	if got := tr.baseURL().String(); got != "https://proxy.example.com/comagic" {
+		t.Errorf("got base url %q, want one from environment", got)
+	}
+}
+
+func TestNewFromEnvToken(t *testing.T) {
+	t.Setenv(EnvLogin, "")
+	t.Setenv(EnvPassword, "")
+	t.Setenv(EnvToken, "token")
+	t.Setenv(EnvBaseURL, "")
+	c, err := NewFromEnv(WithTokenInHeader())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tr := c.Transport.(*Transport)
+	if tr.token != "token" || !tr.tokenInHeader {
+		t.Errorf("got token %q in header %v, want token from environment with given options", tr.token, tr.tokenInHeader)
+	}
+	if tr.baseURL() != DefaultBaseURL {
+		t.Errorf("got base url %q, want default one", tr.baseURL())
+	}
+}
+
+func TestNewFromEnvInvalid(t *testing.T) {
+	tests := []struct {
+		login, password, token, baseURL string
+		want                            string
+	}{
+		{"", "", "", "", "must be set"},
+		{"login", "", "", "", "must be set"},
+		{"", "password", "", "", "must be set"},
+		{"login", "password", "token", "", "could not be combined"},
+		{"login", "password", "", "api.comagic.ru", "invalid " + EnvBaseURL},
+		{"login", "password", "", "://", "invalid " + EnvBaseURL},
+	}
+	for _, tt := range tests {
+		t.Setenv(EnvLogin, tt.login)
+		t.Setenv(EnvPassword, tt.password)
+		t.Setenv(EnvToken, tt.token)
+		t.Setenv(EnvBaseURL, tt.baseURL)
+		c, err := NewFromEnv()
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%+v: got error %v, want %q", tt, err, tt.want)
+		}
+		if c != nil {
+			t.Errorf("%+v: got client with invalid environment", tt)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package comagic
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCredentials is reported when API rejects login or password
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// ErrAccountLocked is reported when API temporary locks account after
+// too many failed login attempts, login should not be retried until lock expires.
+// Transport remembers lock of known duration and fails requests without login until it expires
+var ErrAccountLocked = errors.New("account locked")
+
+// lockoutCodes are error codes API reports for locked account
+var lockoutCodes = map[string]bool{
+	"account_locked":    true,
+	"too_many_attempts": true,
+}
+
+// ErrAuthTimeout is reported when authorization request exceeds auth timeout
+var ErrAuthTimeout = errors.New("authorization timeout")
+
+// ErrChallenged is reported when API gateway responds with captcha or bot
+// challenge page instead of JSON, requests are likely blocked by address
+// and retrying them does not help
+var ErrChallenged = errors.New("request challenged by bot protection")
+
+// challengeMarkers are lowercase fragments of bot challenge pages
+var challengeMarkers = []string{
+	"captcha",
+	"cf-chl",
+	"challenge-form",
+	"checking your browser",
+	"bot protection",
+}
+
+// AuthError is an error returned when authorization request fails
+type AuthError struct {
+	// StatusCode of authorization response, zero if request was not made
+	StatusCode int
+	// Message returned by API
+	Message string
+	// Err is an underlying error
+	Err error
+	// RetryAfter is a duration of account lock if API reported it
+	RetryAfter time.Duration
+}
+
+func (e *AuthError) Error() string {
+	switch {
+	case e.Locked() && e.RetryAfter > 0:
+		return fmt.Sprintf("auth: account locked for %s", e.RetryAfter)
+	case e.Locked():
+		return "auth: account locked"
+	case e.StatusCode >= http.StatusBadRequest:
+		msg := fmt.Sprintf("auth: invalid response: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+		if e.Err != nil && e.Err != ErrInvalidCredentials {
+			msg += ": " + e.Err.Error()
+		}
+		return msg
+	case len(e.Message) > 0:
+		return "auth: request failed: " + e.Message
+	case e.Err != nil:
+		return "auth: " + e.Err.Error()
+	}
+	return "auth: failed"
+}
+
+// Unwrap returns underlying error
+func (e *AuthError) Unwrap() error {
+	return e.Err
+}
+
+// Locked reports whether account is temporary locked
+func (e *AuthError) Locked() bool {
+	return errors.Is(e.Err, ErrAccountLocked)
+}
+
+// Timeout reports whether authorization exceeded auth timeout
+func (e *AuthError) Timeout() bool {
+	return errors.Is(e.Err, ErrAuthTimeout)
+}
+
+// APIError is an error reported by API in response payload
+type APIError struct {
+	Code    string
+	Message string
+}
+
+func (e *APIError) Error() string {
+	if len(e.Code) > 0 {
+		return fmt.Sprintf("api error: %s: %s", e.Code, e.Message)
+	}
+	return "api error: " + e.Message
+}
+
+// accountLocked reports whether login response reports locked account,
+// 429 without lockout code is a plain rate limiting
+func accountLocked(ar authResp) bool {
+	return !ar.Success && lockoutCodes[ar.Code]
+}
+
+// lockWindow returns lock duration reported by Retry-After header or response data
+func lockWindow(res *http.Response, ar authResp, now time.Time) time.Duration {
+	if d, ok := parseRetryAfter(res.Header.Get("Retry-After"), now); ok {
+		return d
+	}
+	if ar.Data.RetryAfter > 0 {
+		return time.Duration(ar.Data.RetryAfter) * time.Second
+	}
+	return 0
+}
+
+// RateLimitError is an error returned when API rejects request
+// with 429 Too Many Requests
+type RateLimitError struct {
+	// RetryAfter is a duration to wait before next request, zero if unknown
+	RetryAfter time.Duration
+	// RateLimit holds X-RateLimit-* response headers by canonical name
+	RateLimit map[string]string
+}
+
+func (e *RateLimitError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("rate limited: retry after %s", e.RetryAfter)
+	}
+	return "rate limited"
+}
+
+// rateLimited returns RateLimitError describing 429 response
+func rateLimited(res *http.Response, now time.Time) *RateLimitError {
+	e := &RateLimitError{RateLimit: map[string]string{}}
+	e.RetryAfter, _ = parseRetryAfter(res.Header.Get("Retry-After"), now)
+	for k, vs := range res.Header {
+		if strings.HasPrefix(k, "X-Ratelimit-") && len(vs) > 0 {
+			e.RateLimit[k] = vs[0]
+		}
+	}
+	return e
+}
+
+// looksJSON reports whether body starts as JSON object or array
+func looksJSON(body []byte) bool {
+	b := bytes.TrimLeft(body, " \t\r\n")
+	return len(b) > 0 && (b[0] == '{' || b[0] == '[')
+}
+
+// errNonJSON is wrapped by errors of responses which are not JSON payload
+var errNonJSON = errors.New("unexpected non JSON response")
+
+// nonJSON returns error describing response which is not JSON payload,
+// e.g. HTML error page of the gateway. Bot challenge page is ErrChallenged
+func nonJSON(res *http.Response, body []byte) error {
+	ct := res.Header.Get("Content-Type")
+	if len(ct) == 0 {
+		ct = "unknown content type"
+	}
+	if challenged(ct, body) {
+		return fmt.Errorf("%w (%s): %d %s", ErrChallenged, ct, res.StatusCode, http.StatusText(res.StatusCode))
+	}
+	return fmt.Errorf("%w (%s): %d %s: %q", errNonJSON,
+		ct, res.StatusCode, http.StatusText(res.StatusCode), snippet(body))
+}
+
+// HTTPError is an error returned when API responds with non 2xx status
+// other than 429 Too Many Requests which is reported as RateLimitError
+type HTTPError struct {
+	StatusCode int
+	// Body is a beginning of response body up to 64KB
+	Body []byte
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("http error: %d %s: %q", e.StatusCode, http.StatusText(e.StatusCode), snippet(e.Body))
+}
+
+// IsBadRequest reports whether response status is 400 Bad Request
+func (e *HTTPError) IsBadRequest() bool {
+	return e.StatusCode == http.StatusBadRequest
+}
+
+// IsUnauthorized reports whether response status is 401 Unauthorized
+func (e *HTTPError) IsUnauthorized() bool {
+	return e.StatusCode == http.StatusUnauthorized
+}
+
+// IsForbidden reports whether response status is 403 Forbidden
+func (e *HTTPError) IsForbidden() bool {
+	return e.StatusCode == http.StatusForbidden
+}
+
+// IsNotFound reports whether response status is 404 Not Found
+func (e *HTTPError) IsNotFound() bool {
+	return e.StatusCode == http.StatusNotFound
+}
+
+// IsClientError reports whether response status is 4xx
+func (e *HTTPError) IsClientError() bool {
+	return e.StatusCode >= 400 && e.StatusCode < 500
+}
+
+// IsServerError reports whether response status is 5xx
+func (e *HTTPError) IsServerError() bool {
+	return e.StatusCode >= 500
+}
+
+// challenged reports whether response is HTML page of bot challenge
+func challenged(contentType string, body []byte) bool {
+	if !strings.Contains(strings.ToLower(contentType), "text/html") {
+		return false
+	}
+	if len(body) > maxErrorSize {
+		body = body[:maxErrorSize]
+	}
+	page := bytes.ToLower(body)
+	for _, m := range challengeMarkers {
+		if bytes.Contains(page, []byte(m)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidationError is an error returned when request parameters are rejected
+// before request is sent
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Field, e.Message)
+}
@@ -0,0 +1,378 @@
+package comagic
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nk2ge5k/go-api-comagic/comagictest"
+)
+
+const challengePage = `<html><head><title>Just a moment...</title></head>
+<body><form id="challenge-form" action="/cdn-cgi/l/chk_captcha">Checking your browser</form></body></html>`
+
+// newLoginServer returns server answering login requests with given handler
+func newLoginServer(t *testing.T, h http.HandlerFunc) *url.URL {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestAuthChallenged(t *testing.T) {
+	for _, status := range []int{http.StatusOK, http.StatusForbidden, http.StatusServiceUnavailable} {
+		base := newLoginServer(t, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.WriteHeader(status)
+			w.Write([]byte(challengePage))
+		})
+		tr := New("login", "password", WithBaseURL(base)).Transport.(*Transport)
+		err := tr.Authenticate(context.Background())
+		if !errors.Is(err, ErrChallenged) {
+			t.Errorf("status %d: got error %v, want ErrChallenged", status, err)
+		}
+		if errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("status %d: challenge page reported as invalid credentials", status)
+		}
+	}
+}
+
+func TestAPIChallenged(t *testing.T) {
+	for _, status := range []int{http.StatusOK, http.StatusForbidden, http.StatusServiceUnavailable} {
+		page := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			w.WriteHeader(status)
+			w.Write([]byte(challengePage))
+		})
+		srv := comagictest.NewServer(t,
+			comagictest.WithHandler("/api/sites/", page),
+			comagictest.WithHandler("/api/calls_report/", page),
+		)
+		c := NewClient(New("login", "password", WithBaseURL(srv.URL())))
+		if _, err := c.Sites(context.Background()); !errors.Is(err, ErrChallenged) {
+			t.Errorf("status %d: got error %v, want ErrChallenged", status, err)
+		}
+
+		from := time.Now().Add(-time.Hour)
+		req := CallsReportRequest{DateFrom: from, DateTill: from.Add(time.Minute)}
+		err := c.CallsReportStream(context.Background(), req, func(Call) error { return nil })
+		if !errors.Is(err, ErrChallenged) {
+			t.Errorf("status %d: got stream error %v, want ErrChallenged", status, err)
+		}
+		buf := &strings.Builder{}
+		if err := c.CallsReportCSV(context.Background(), req, buf); !errors.Is(err, ErrChallenged) {
+			t.Errorf("status %d: got export error %v, want ErrChallenged", status, err)
+		}
+		if buf.Len() != 0 {
+			t.Errorf("status %d: got challenge page written as export", status)
+		}
+	}
+}
+
+func TestChallenged(t *testing.T) {
+	tests := []struct {
+		contentType string
+		body        string
+		want        bool
+	}{
+		{"text/html", challengePage, true},
+		{"text/html; charset=utf-8", "<p>Please solve CAPTCHA</p>", true},
+		{"text/html", "<h1>502 Bad Gateway</h1>", false},
+		{"text/plain", "captcha", false},
+	}
+	for _, tt := range tests {
+		if got := challenged(tt.contentType, []byte(tt.body)); got != tt.want {
+			t.Errorf("challenged(%q, %q) = %v, want %v", tt.contentType, tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestAuthAccountLocked(t *testing.T) {
+	logins := 0
+	base := newLoginServer(t, func(w http.ResponseWriter, r *http.Request) {
+		logins++
+		w.Write([]byte(`{"success":false,"code":"account_locked","message":"too many attempts","data":{"retry_after":600}}`))
+	})
+	c := New("login", "password", WithBaseURL(base),
+		WithRetry(RetryPolicy{MaxAttempts: 3}), WithAuthRetries(3))
+	req, _ := http.NewRequest(http.MethodGet, "/api/x/", nil)
+	_, err := c.Do(req)
+	ae := &AuthError{}
+	if !errors.As(err, &ae) {
+		t.Fatalf("got error %v, want AuthError", err)
+	}
+	if !errors.Is(err, ErrAccountLocked) || !ae.Locked() {
+		t.Fatalf("got error %v, want ErrAccountLocked", err)
+	}
+	if ae.RetryAfter != 10*time.Minute || ae.Message != "too many attempts" {
+		t.Errorf("got retry after %s and message %q, want 10m and API message", ae.RetryAfter, ae.Message)
+	}
+	if logins != 1 {
+		t.Errorf("got %d login requests, locked account must not be retried", logins)
+	}
+}
+
+func TestAuthAccountLockedRemembered(t *testing.T) {
+	var logins atomic.Int32
+	base := newLoginServer(t, func(w http.ResponseWriter, r *http.Request) {
+		logins.Add(1)
+		w.Write([]byte(`{"success":false,"code":"account_locked","message":"too many attempts","data":{"retry_after":600}}`))
+	})
+	clock := newFakeClock()
+	c := New("login", "password", WithBaseURL(base), WithClock(clock.Now))
+	do := func() error {
+		req, _ := http.NewRequest(http.MethodGet, "/api/x/", nil)
+		_, err := c.Do(req)
+		return err
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := do(); !errors.Is(err, ErrAccountLocked) {
+			t.Fatalf("got error %v, want ErrAccountLocked", err)
+		}
+	}
+	if n := logins.Load(); n != 1 {
+		t.Fatalf("got %d login requests, want login skipped while account is locked", n)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := do(); !errors.Is(err, ErrAccountLocked) {
+				t.Errorf("got error %v, want ErrAccountLocked", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := logins.Load(); n != 1 {
+		t.Fatalf("got %d login requests from concurrent requests, want login skipped while account is locked", n)
+	}
+
+	clock.Advance(4 * time.Minute)
+	ae := &AuthError{}
+	if err := do(); !errors.As(err, &ae) || ae.RetryAfter != 6*time.Minute {
+		t.Fatalf("got error %v, want ErrAccountLocked with 6m left", err)
+	}
+	clock.Advance(6 * time.Minute)
+	do()
+	if n := logins.Load(); n != 2 {
+		t.Errorf("got %d login requests, want login retried once lock expired", n)
+	}
+}
+
+func TestAuthRateLimited(t *testing.T) {
+	base := newLoginServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"success":false,"message":"slow down"}`))
+	})
+	tr := New("login", "password", WithBaseURL(base)).Transport.(*Transport)
+	err := tr.Authenticate(context.Background())
+	ae := &AuthError{}
+	if !errors.As(err, &ae) || ae.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("got error %v, want AuthError with status 429", err)
+	}
+	if ae.Locked() || errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("rate limited login reported as locked account: %v", err)
+	}
+	rle := &RateLimitError{}
+	if !errors.As(err, &rle) || rle.RetryAfter != 30*time.Second {
+		t.Fatalf("got error %v, want RateLimitError with 30s retry after", err)
+	}
+}
+
+func TestAuthError(t *testing.T) {
+	tests := []struct {
+		status  int
+		body    string
+		message string
+		is      error
+	}{
+		{http.StatusOK, `{"success":false,"message":"wrong password"}`, "wrong password", ErrInvalidCredentials},
+		{http.StatusUnauthorized, `{"success":false,"message":"bad login"}`, "bad login", ErrInvalidCredentials},
+		{http.StatusBadRequest, `{"success":false,"message":"login is required"}`, "login is required", nil},
+		{http.StatusInternalServerError, `{"success":false,"message":"internal error"}`, "internal error", nil},
+	}
+	for _, tt := range tests {
+		base := newLoginServer(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+			w.Write([]byte(tt.body))
+		})
+		c := New("login", "password", WithBaseURL(base), WithRetry(RetryPolicy{MaxAttempts: 1}))
+		req, _ := http.NewRequest(http.MethodGet, "/api/x/", nil)
+		_, err := c.Do(req)
+		ae := &AuthError{}
+		if !errors.As(err, &ae) {
+			t.Fatalf("status %d: got error %v, want AuthError", tt.status, err)
+		}
+		if ae.StatusCode != tt.status || ae.Message != tt.message {
+			t.Errorf("status %d: got status %d and message %q, want %d and %q",
+				tt.status, ae.StatusCode, ae.Message, tt.status, tt.message)
+		}
+		if tt.is != nil && !errors.Is(err, tt.is) {
+			t.Errorf("status %d: got error %v, want %v", tt.status, err, tt.is)
+		}
+		if tt.is == nil && errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("status %d: server failure reported as invalid credentials", tt.status)
+		}
+	}
+}
+
+func TestAuthDecodeError(t *testing.T) {
+	base := newLoginServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"success":`))
+	})
+	tr := New("login", "password", WithBaseURL(base)).Transport.(*Transport)
+	err := tr.Authenticate(context.Background())
+	ae := &AuthError{}
+	if !errors.As(err, &ae) || ae.Err == nil {
+		t.Fatalf("got error %v, want AuthError with decode error", err)
+	}
+	if errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("decode failure reported as invalid credentials: %v", err)
+	}
+}
+
+const gatewayPage = "<html><body><h1>503 Service Temporarily Unavailable</h1></body></html>"
+
+func TestAuthNonJSON(t *testing.T) {
+	base := newLoginServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(gatewayPage))
+	})
+	tr := New("login", "password", WithBaseURL(base)).Transport.(*Transport)
+	err := tr.Authenticate(context.Background())
+	ae := &AuthError{}
+	if !errors.As(err, &ae) || ae.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("got error %v, want AuthError with 503 status", err)
+	}
+	if msg := err.Error(); !strings.Contains(msg, "503") || !strings.Contains(msg, "Temporarily Unavailable") {
+		t.Errorf("got error %q, want status and body snippet", msg)
+	}
+	if strings.Contains(err.Error(), "invalid character") {
+		t.Errorf("got decoder error %q", err)
+	}
+}
+
+func TestAPINonJSON(t *testing.T) {
+	c := stubClient(http.StatusOK, "text/html", gatewayPage)
+	_, err := c.Sites(context.Background())
+	if err == nil {
+		t.Fatal("got no error of HTML response")
+	}
+	if msg := err.Error(); !strings.Contains(msg, "200") || !strings.Contains(msg, "text/html") || !strings.Contains(msg, "Temporarily Unavailable") {
+		t.Errorf("got error %q, want status, content type and body snippet", msg)
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	long := strings.Repeat("a", snippetSize+10)
+	if got := snippet([]byte(long)); got != long[:snippetSize]+"..." {
+		t.Errorf("got snippet of %d bytes, want body cut to %d", len(got), snippetSize)
+	}
+	if got := snippet([]byte("short")); got != "short" {
+		t.Errorf("got snippet %q, want short body as is", got)
+	}
+}
+
+func TestRateLimitError(t *testing.T) {
+	tests := []struct {
+		retryAfter string
+		min, max   time.Duration
+	}{
+		{"30", 30 * time.Second, 30 * time.Second},
+		{time.Now().Add(2 * time.Minute).UTC().Format(http.TimeFormat), time.Minute, 2 * time.Minute},
+		{"", 0, 0},
+	}
+	for _, tt := range tests {
+		srv := comagictest.NewServer(t, comagictest.WithHandler("/api/sites/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(tt.retryAfter) > 0 {
+				w.Header().Set("Retry-After", tt.retryAfter)
+			}
+			w.Header().Set("X-RateLimit-Limit", "100")
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		})))
+		c := NewClient(New("login", "password", WithBaseURL(srv.URL())))
+		_, err := c.Sites(context.Background())
+		rle := &RateLimitError{}
+		if !errors.As(err, &rle) {
+			t.Fatalf("Retry-After %q: got error %v, want RateLimitError", tt.retryAfter, err)
+		}
+		if rle.RetryAfter < tt.min || rle.RetryAfter > tt.max {
+			t.Errorf("Retry-After %q: got retry after %s, want in [%s, %s]", tt.retryAfter, rle.RetryAfter, tt.min, tt.max)
+		}
+		if rle.RateLimit["X-Ratelimit-Limit"] != "100" || rle.RateLimit["X-Ratelimit-Remaining"] != "0" {
+			t.Errorf("got rate limit headers %v, want limit and remaining", rle.RateLimit)
+		}
+	}
+}
+
+func TestHTTPError(t *testing.T) {
+	tests := []struct {
+		status    int
+		predicate func(*HTTPError) bool
+		client    bool
+		server    bool
+	}{
+		{http.StatusBadRequest, (*HTTPError).IsBadRequest, true, false},
+		{http.StatusUnauthorized, (*HTTPError).IsUnauthorized, true, false},
+		{http.StatusForbidden, (*HTTPError).IsForbidden, true, false},
+		{http.StatusNotFound, (*HTTPError).IsNotFound, true, false},
+		{http.StatusConflict, nil, true, false},
+		{http.StatusInternalServerError, nil, false, true},
+		{http.StatusBadGateway, nil, false, true},
+	}
+	for _, tt := range tests {
+		c := NewClient(ClientFunc(func(r *http.Request) (*http.Response, error) {
+			return stubResponse(r, tt.status, `{"error":"details"}`), nil
+		}))
+		_, err := c.Sites(context.Background())
+		he := &HTTPError{}
+		if !errors.As(err, &he) || he.StatusCode != tt.status {
+			t.Fatalf("status %d: got error %v, want HTTPError", tt.status, err)
+		}
+		if string(he.Body) != `{"error":"details"}` {
+			t.Errorf("status %d: got body %q", tt.status, he.Body)
+		}
+		if tt.predicate != nil && !tt.predicate(he) {
+			t.Errorf("status %d: status predicate is false", tt.status)
+		}
+		if he.IsClientError() != tt.client || he.IsServerError() != tt.server {
+			t.Errorf("status %d: got client error %v and server error %v", tt.status, he.IsClientError(), he.IsServerError())
+		}
+		if msg := err.Error(); !strings.Contains(msg, http.StatusText(tt.status)) {
+			t.Errorf("status %d: got error %q, want status text", tt.status, msg)
+		}
+	}
+}
+
+func TestHTTPErrorBodyLimit(t *testing.T) {
+	body := strings.Repeat("a", 2*maxErrorSize)
+	c := NewClient(ClientFunc(func(r *http.Request) (*http.Response, error) {
+		return stubResponse(r, http.StatusBadRequest, body), nil
+	}))
+	_, err := c.Sites(context.Background())
+	he := &HTTPError{}
+	if !errors.As(err, &he) {
+		t.Fatalf("got error %v, want HTTPError", err)
+	}
+	if len(he.Body) != maxErrorSize {
+		t.Errorf("got body of %d bytes, want %d", len(he.Body), maxErrorSize)
+	}
+}
@@ -0,0 +1,35 @@
+package comagic_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	comagic "github.com/nk2ge5k/go-api-comagic"
+)
+
+func ExampleClientFunc() {
+	// fake doer answers every request without network
+	fake := comagic.ClientFunc(func(r *http.Request) (*http.Response, error) {
+		body := `{"success":true,"data":[{"number":"74950000001","type":"static","ac_id":7}]}`
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(body)),
+			Request:    r,
+		}, nil
+	})
+	c := comagic.NewClient(fake)
+	numbers, err := c.VirtualNumbers(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, n := range numbers {
+		fmt.Println(n.Number, n.Type, n.CampaignID)
+	}
+	// Output:
+	// 74950000001 static 7
+}
@@ -0,0 +1,52 @@
+package comagic
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// unknownFields returns fields of JSON object which are not mapped
+// to fields of struct v by json tags, so typed responses keep fields
+// added to API after the package was released
+func unknownFields(data []byte, v interface{}) (map[string]json.RawMessage, error) {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for _, name := range jsonNames(reflect.TypeOf(v)) {
+		delete(fields, name)
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	return fields, nil
+}
+
+// namesCache caches JSON names of struct fields by type
+var namesCache sync.Map
+
+// jsonNames returns JSON names of struct fields
+func jsonNames(typ reflect.Type) []string {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if names, ok := namesCache.Load(typ); ok {
+		return names.([]string)
+	}
+	names := make([]string, 0, typ.NumField())
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		switch {
+		case name == "-":
+			continue
+		case len(name) == 0:
+			name = f.Name
+		}
+		names = append(names, name)
+	}
+	namesCache.Store(typ, names)
+	return names
+}
@@ -0,0 +1,44 @@
+package comagic
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestExtra(t *testing.T) {
+	payload := []byte(`{"id":1,"numa":"74950000000","recording_duration":30,"labels":["a","b"]}`)
+	call := Call{}
+	if err := json.Unmarshal(payload, &call); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if call.ID != 1 || call.CallerNumber != "74950000000" {
+		t.Errorf("got call %+v, want known fields decoded", call)
+	}
+	want := map[string]json.RawMessage{
+		"recording_duration": json.RawMessage(`30`),
+		"labels":             json.RawMessage(`["a","b"]`),
+	}
+	if !reflect.DeepEqual(call.Extra, want) {
+		t.Errorf("got extra %s, want unknown fields", call.Extra)
+	}
+
+	site := Site{}
+	if err := json.Unmarshal([]byte(`{"id":1,"domain":"example.com"}`), &site); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if site.Extra != nil {
+		t.Errorf("got extra %s of payload without unknown fields, want nil", site.Extra)
+	}
+}
+
+func TestJSONNames(t *testing.T) {
+	type record struct {
+		ID      int `json:"id,omitempty"`
+		Name    string
+		Skipped string `json:"-"`
+	}
+	if got := jsonNames(reflect.TypeOf(&record{})); !reflect.DeepEqual(got, []string{"id", "Name"}) {
+		t.Errorf("got names %q, want [id Name]", got)
+	}
+}
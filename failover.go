@@ -0,0 +1,93 @@
+package comagic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+// hostKey is a context key of base URL request is sent to
+type hostKey struct{}
+
+// WithBaseURLs is an option function setting primary base URL
+// and fallback ones. Relative idempotent requests failed with server error
+// or connection error are sent to the next base URL in order, session
+// rejected by fallback host is renewed against that host. Other requests
+// fail over only if they never reached the server: connection to it could
+// not be established or authorization against it failed
+func WithBaseURLs(urls ...*url.URL) func(*Transport) {
+	return func(t *Transport) {
+		if len(urls) == 0 {
+			return
+		}
+		t.BaseURL = urls[0]
+		t.failover = append([]*url.URL(nil), urls[1:]...)
+	}
+}
+
+// host returns base URL request with given context is sent to
+func (t *Transport) host(ctx context.Context) *url.URL {
+	if u, ok := ctx.Value(hostKey{}).(*url.URL); ok {
+		return u
+	}
+	return t.baseURL()
+}
+
+// failOver sends request to fallback base URLs while response
+// or error is transient, last response or error is returned
+func (t *Transport) failOver(r *http.Request, ref *url.URL, span Span, compressed bool, res *http.Response, err error) (*http.Response, error) {
+	for _, host := range t.failover {
+		if !failoverable(r, res, err) {
+			return res, err
+		}
+		if res != nil {
+			io.Copy(io.Discard, io.LimitReader(res.Body, maxErrorSize))
+			res.Body.Close()
+		}
+		if err := rewind(r); err != nil {
+			return nil, fmt.Errorf("round trip: could not rewind request body: %w", err)
+		}
+		span.SetAttribute("comagic.failover", host.Host)
+		if t.logger != nil {
+			attrs := []slog.Attr{slog.String("event", "failover"), slog.String("host", host.Host)}
+			if err != nil {
+				attrs = append(attrs, slog.String("error", err.Error()))
+			} else {
+				attrs = append(attrs, slog.Int("status", res.StatusCode))
+			}
+			t.log(r.Context(), slog.LevelWarn, "base url failover", attrs...)
+		}
+		r = r.WithContext(context.WithValue(r.Context(), hostKey{}, host))
+		t.resolve(r, host, ref)
+		res, err = t.attempts(r, span, compressed)
+	}
+	return res, err
+}
+
+// failoverable reports whether request should be sent to the next base URL,
+// that is the case for server errors and connection failures of idempotent
+// request and for failures before request is sent of any request
+func failoverable(r *http.Request, res *http.Response, err error) bool {
+	if r.Context().Err() != nil {
+		return false
+	}
+	if err == nil {
+		return res.StatusCode >= http.StatusInternalServerError && idempotent(r)
+	}
+	var ae *AuthError
+	if errors.As(err, &ae) && ae.StatusCode >= http.StatusInternalServerError {
+		return true
+	}
+	var oe *net.OpError
+	if errors.As(err, &oe) && oe.Op == "dial" {
+		// connection is not established, so nothing is sent
+		return true
+	}
+	var ne net.Error
+	return errors.As(err, &ne) && idempotent(r)
+}
@@ -0,0 +1,111 @@
+package comagic
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/nk2ge5k/go-api-comagic/comagictest"
+)
+
+func TestFailover(t *testing.T) {
+	primary := comagictest.NewServer(t, comagictest.WithHandler("/api/x/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})))
+	fallback := comagictest.NewServer(t, comagictest.WithSessionKey("fallback-key"),
+		comagictest.WithSessionKeyCheck(false), comagictest.WithData("/api/x/", nil))
+	c := New("login", "password", WithBaseURLs(primary.URL(), fallback.URL()))
+	req, _ := http.NewRequest(http.MethodGet, "/api/x/", nil)
+	res, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d, want 200 of fallback host", res.StatusCode)
+	}
+	if got, want := res.Request.URL.Host, fallback.URL().Host; got != want {
+		t.Errorf("got response of host %s, want %s", got, want)
+	}
+	if primary.Logins() != 1 || fallback.Logins() != 1 {
+		t.Errorf("got %d primary and %d fallback logins, want session renewed against fallback host",
+			primary.Logins(), fallback.Logins())
+	}
+}
+
+func TestFailoverConnectionError(t *testing.T) {
+	down := httptest.NewServer(http.NotFoundHandler())
+	primary, _ := url.Parse(down.URL)
+	down.Close()
+	fallback := comagictest.NewServer(t, comagictest.WithData("/api/x/", nil))
+	c := New("login", "password", WithBaseURLs(primary, fallback.URL()), WithRetry(RetryPolicy{MaxAttempts: 1}))
+	req, _ := http.NewRequest(http.MethodGet, "/api/x/", nil)
+	res, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	res.Body.Close()
+	if got, want := res.Request.URL.Host, fallback.URL().Host; got != want {
+		t.Errorf("got response of host %s, want %s", got, want)
+	}
+}
+
+func TestFailoverClientError(t *testing.T) {
+	primary := comagictest.NewServer(t, comagictest.WithHandler("/api/x/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	})))
+	var requests atomic.Int32
+	fallback := comagictest.NewServer(t, comagictest.WithHandler("/api/x/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+	})))
+	c := New("login", "password", WithBaseURLs(primary.URL(), fallback.URL()))
+	req, _ := http.NewRequest(http.MethodGet, "/api/x/", nil)
+	res, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest || requests.Load() != 0 || fallback.Logins() != 0 {
+		t.Errorf("got status %d and %d fallback requests, client error must not fail over",
+			res.StatusCode, requests.Load())
+	}
+}
+
+func TestFailoverNotIdempotent(t *testing.T) {
+	primary := comagictest.NewServer(t, comagictest.WithHandler("/api/x/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})))
+	var requests atomic.Int32
+	fallback := comagictest.NewServer(t, comagictest.WithHandler("/api/x/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+	})))
+	c := New("login", "password", WithBaseURLs(primary.URL(), fallback.URL()))
+	req, _ := http.NewRequest(http.MethodPost, "/api/x/", strings.NewReader(`{"contact":"79000000000"}`))
+	res, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusServiceUnavailable || requests.Load() != 0 {
+		t.Errorf("got status %d and %d fallback requests, POST must not be sent again to fallback host",
+			res.StatusCode, requests.Load())
+	}
+
+	// request never reached primary host, so it is safe to send it to fallback one
+	down := httptest.NewServer(http.NotFoundHandler())
+	u, _ := url.Parse(down.URL)
+	down.Close()
+	c = New("login", "password", WithBaseURLs(u, fallback.URL()))
+	req, _ = http.NewRequest(http.MethodPost, "/api/x/", strings.NewReader(`{"contact":"79000000000"}`))
+	res, err = c.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	res.Body.Close()
+	if requests.Load() != 1 {
+		t.Errorf("got %d fallback requests, want POST failed to connect sent to fallback host", requests.Load())
+	}
+}
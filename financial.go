@@ -0,0 +1,65 @@
+package comagic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// FinancialReportRequest is a financial report request parameters
+type FinancialReportRequest struct {
+	DateFrom time.Time
+	DateTill time.Time
+	// Offset and Limit used for pagination, zero limit means API default
+	Offset int
+	Limit  int
+}
+
+// FinancialReportResponse is a financial report
+type FinancialReportResponse struct {
+	Legs []CallLegCharge
+}
+
+// CallLegCharge is a charge for a single call leg
+type CallLegCharge struct {
+	ID            int64    `json:"id"`
+	CallID        int64    `json:"call_id"`
+	StartTime     DateTime `json:"start_time"`
+	Direction     string   `json:"direction"`
+	ContactNumber string   `json:"contact_phone_number"`
+	VirtualNumber string   `json:"virtual_phone_number"`
+	Duration      Duration `json:"duration"`
+	Cost          Money    `json:"cost"`
+	Currency      string   `json:"currency"`
+	// Extra holds response fields unknown to the package
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler interface
+func (l *CallLegCharge) UnmarshalJSON(b []byte) error {
+	type plain CallLegCharge
+	if err := json.Unmarshal(b, (*plain)(l)); err != nil {
+		return err
+	}
+	extra, err := unknownFields(b, l)
+	l.Extra = extra
+	return err
+}
+
+// FinancialReport returns call legs charges for requested period
+func (c *Client) FinancialReport(ctx context.Context, req FinancialReportRequest) (FinancialReportResponse, error) {
+	resp := FinancialReportResponse{Legs: []CallLegCharge{}}
+	if err := c.validatePeriod(req.DateFrom, req.DateTill); err != nil {
+		return resp, fmt.Errorf("financial report: %w", err)
+	}
+	legs, err := getPaged[CallLegCharge](ctx, c, "/api/financial_call_legs_report/", func(offset, limit int) url.Values {
+		return reportQuery(c.loc, req.DateFrom, req.DateTill, offset, limit)
+	}, req.Offset, req.Limit)
+	if err != nil {
+		return resp, fmt.Errorf("financial report: %w", err)
+	}
+	resp.Legs = legs
+	return resp, nil
+}
@@ -0,0 +1,61 @@
+package comagic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/nk2ge5k/go-api-comagic/comagictest"
+)
+
+func TestFinancialReport(t *testing.T) {
+	srv := comagictest.NewServer(t, comagictest.WithData("/api/financial_call_legs_report/", json.RawMessage(`[
+		{"id":1,"call_id":10,"start_time":"2024-01-02 03:04:05","direction":"out","duration":61,"cost":"0.1","currency":"RUB"},
+		{"id":2,"call_id":10,"start_time":"2024-01-02 03:05:06","direction":"out","duration":"120","cost":0.2,"currency":"RUB","tariff":"base"},
+		{"id":3,"call_id":11,"start_time":"2024-01-02 04:00:00","direction":"in","duration":0,"cost":"12345678901234.1234","currency":"RUB"}
+	]`)))
+	c := NewClient(New("login", "password", WithBaseURL(srv.URL())))
+	resp, err := c.FinancialReport(context.Background(), FinancialReportRequest{
+		DateFrom: time.Now().Add(-time.Hour),
+		DateTill: time.Now(),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(resp.Legs) != 3 {
+		t.Fatalf("got %d legs, want 3", len(resp.Legs))
+	}
+	if got := resp.Legs[0].Cost.Add(resp.Legs[1].Cost).String(); got != "0.3" {
+		t.Errorf("got sum of costs %s, want exact 0.3", got)
+	}
+	if got := resp.Legs[2].Cost.String(); got != "12345678901234.1234" {
+		t.Errorf("got cost %s, want 12345678901234.1234", got)
+	}
+	if got := resp.Legs[1].Duration.Duration(); got != 2*time.Minute {
+		t.Errorf("got duration %s, want 2m", got)
+	}
+	if leg := resp.Legs[1]; leg.CallID != 10 || leg.Currency != "RUB" || string(leg.Extra["tariff"]) != `"base"` {
+		t.Errorf("got leg %+v, want call 10 in RUB with extra tariff", leg)
+	}
+}
+
+func TestFinancialReportAPIError(t *testing.T) {
+	srv := comagictest.NewServer(t, comagictest.WithHandler("/api/financial_call_legs_report/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		comagictest.RespondError(w, "access_denied", "no access to financial data")
+	})))
+	c := NewClient(New("login", "password", WithBaseURL(srv.URL())))
+	resp, err := c.FinancialReport(context.Background(), FinancialReportRequest{
+		DateFrom: time.Now().Add(-time.Hour),
+		DateTill: time.Now(),
+	})
+	ae := &APIError{}
+	if !errors.As(err, &ae) || ae.Code != "access_denied" {
+		t.Fatalf("got error %v, want access_denied APIError", err)
+	}
+	if resp.Legs == nil || len(resp.Legs) != 0 {
+		t.Errorf("got legs %v, want empty list", resp.Legs)
+	}
+}
@@ -0,0 +1,69 @@
+package comagic
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// WithCompression is an option function for switching gzip compression
+// of responses, enabled by default. Compressed responses are decompressed
+// transparently. When disabled, compression is left to underlying transport
+func WithCompression(enabled bool) func(*Transport) {
+	return func(t *Transport) { t.noCompression = !enabled }
+}
+
+// decompress replaces gzip encoded response body with decompressed one.
+// Gzip header is read on the first Read, so empty body of response flagged
+// as gzip encoded is read as empty instead of failing
+func decompress(res *http.Response) error {
+	if !strings.EqualFold(res.Header.Get("Content-Encoding"), "gzip") {
+		return nil
+	}
+	if !bodyAllowed(res) {
+		return nil
+	}
+	res.Body = &gzipBody{body: res.Body}
+	res.Header.Del("Content-Encoding")
+	res.Header.Del("Content-Length")
+	res.ContentLength = -1
+	res.Uncompressed = true
+	return nil
+}
+
+// bodyAllowed reports whether response could have a body
+func bodyAllowed(res *http.Response) bool {
+	if res.Request != nil && res.Request.Method == http.MethodHead {
+		return false
+	}
+	switch res.StatusCode {
+	case http.StatusNoContent, http.StatusNotModified:
+		return false
+	}
+	return res.ContentLength != 0
+}
+
+// gzipBody decompresses body creating gzip reader on the first Read
+type gzipBody struct {
+	body io.ReadCloser
+	zr   *gzip.Reader
+	err  error
+}
+
+func (b *gzipBody) Read(p []byte) (int, error) {
+	if b.zr == nil && b.err == nil {
+		b.zr, b.err = gzip.NewReader(b.body)
+	}
+	if b.err != nil {
+		return 0, b.err
+	}
+	return b.zr.Read(p)
+}
+
+func (b *gzipBody) Close() error {
+	if b.zr != nil {
+		b.zr.Close()
+	}
+	return b.body.Close()
+}
@@ -0,0 +1,118 @@
+package comagic
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/nk2ge5k/go-api-comagic/comagictest"
+)
+
+func gzipped(t *testing.T, s string) []byte {
+	t.Helper()
+	buf := bytes.NewBuffer(nil)
+	zw := gzip.NewWriter(buf)
+	if _, err := zw.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestCompression(t *testing.T) {
+	const payload = `{"success":true,"data":[{"id":1}]}`
+	body := gzipped(t, payload)
+	srv := comagictest.NewServer(t, comagictest.WithHandler("/api/x/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept-Encoding") != "gzip" {
+			t.Errorf("got Accept-Encoding %q, want gzip", r.Header.Get("Accept-Encoding"))
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(body)
+	})))
+	c := New("login", "password", WithBaseURL(srv.URL()))
+
+	// the same request is sent twice to check it is not changed by transport
+	req, _ := http.NewRequest(http.MethodGet, "/api/x/", nil)
+	for i := 0; i < 2; i++ {
+		res, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("request %d: unexpected error: %v", i, err)
+		}
+		b, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			t.Fatalf("request %d: could not read body: %v", i, err)
+		}
+		if string(b) != payload {
+			t.Fatalf("request %d: got body %q, want %q", i, b, payload)
+		}
+		if res.Header.Get("Content-Encoding") != "" || res.ContentLength != -1 {
+			t.Errorf("request %d: got Content-Encoding %q and length %d of decompressed body",
+				i, res.Header.Get("Content-Encoding"), res.ContentLength)
+		}
+	}
+	if len(req.Header) > 0 {
+		t.Errorf("transport changed request headers: %v", req.Header)
+	}
+}
+
+func TestCompressionRequestedByCaller(t *testing.T) {
+	const payload = `{"success":true,"data":[]}`
+	body := gzipped(t, payload)
+	srv := comagictest.NewServer(t, comagictest.WithHandler("/api/x/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(body)
+	})))
+	c := New("login", "password", WithBaseURL(srv.URL()))
+
+	req, _ := http.NewRequest(http.MethodGet, "/api/x/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	res, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer res.Body.Close()
+	b, _ := io.ReadAll(res.Body)
+	if !bytes.Equal(b, body) {
+		t.Fatalf("got body %q, want compressed body left to the caller", b)
+	}
+}
+
+func TestCompressionEmptyBody(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		method string
+		status int
+		flush  bool
+	}{
+		{name: "no content", method: http.MethodGet, status: http.StatusNoContent},
+		{name: "head", method: http.MethodHead, status: http.StatusOK},
+		{name: "zero length", method: http.MethodGet, status: http.StatusOK},
+		{name: "chunked", method: http.MethodGet, status: http.StatusOK, flush: true},
+	} {
+		srv := comagictest.NewServer(t, comagictest.WithHandler("/api/x/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Encoding", "gzip")
+			w.WriteHeader(tc.status)
+			if tc.flush {
+				// flushed headers make body chunked with unknown length
+				w.(http.Flusher).Flush()
+			}
+		})))
+		c := New("login", "password", WithBaseURL(srv.URL()))
+
+		req, _ := http.NewRequest(tc.method, "/api/x/", nil)
+		res, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.name, err)
+		}
+		b, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil || len(b) > 0 {
+			t.Errorf("%s: got body %q and error %v, want empty body", tc.name, b, err)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package comagic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+)
+
+// HealthReport is a summary of API connectivity
+type HealthReport struct {
+	// Reachable is true if API responded to requests
+	Reachable bool
+	// Authenticated is true if session is valid or could be obtained
+	Authenticated bool
+	// Latency is a duration of the health check request including authorization
+	Latency time.Duration
+}
+
+// Health makes cheap authenticated request to API and reports whether API
+// is reachable and session could be obtained, error describes the failure
+// if API is not healthy
+func (c *Client) Health(ctx context.Context) (HealthReport, error) {
+	v := url.Values{}
+	v.Set("limit", "1")
+	start := time.Now()
+	err := c.GetJSON(ctx, "/api/virtual_numbers/", v, nil)
+	report := HealthReport{Latency: time.Since(start)}
+	if err == nil {
+		report.Reachable = true
+		report.Authenticated = true
+		return report, nil
+	}
+	var ae *AuthError
+	var he *HTTPError
+	var ne net.Error
+	switch {
+	case errors.Is(err, ErrChallenged), errors.Is(err, errNonJSON):
+		// gateway answered instead of API, so nothing is known about session
+		report.Reachable = true
+	case errors.As(err, &ae):
+		report.Reachable = ae.StatusCode != 0
+	case errors.As(err, &he) && he.IsUnauthorized():
+		report.Reachable = true
+	case errors.As(err, &ne), ctx.Err() != nil:
+	default:
+		// API responded with an error to authenticated request
+		report.Reachable = true
+		report.Authenticated = true
+	}
+	return report, fmt.Errorf("health: %w", err)
+}
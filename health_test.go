@@ -0,0 +1,60 @@
+package comagic
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/nk2ge5k/go-api-comagic/comagictest"
+)
+
+func TestHealth(t *testing.T) {
+	srv := comagictest.NewServer(t, comagictest.WithCredentials("login", "password"),
+		comagictest.WithHandler("/api/virtual_numbers/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if limit := r.URL.Query().Get("limit"); limit != "1" {
+				t.Errorf("got limit %q, want 1", limit)
+			}
+			comagictest.Respond(w, []interface{}{})
+		})))
+	page := func(contentType, body string) *url.URL {
+		srv := comagictest.NewServer(t, comagictest.WithCredentials("login", "password"),
+			comagictest.WithHandler("/api/virtual_numbers/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", contentType)
+				w.Write([]byte(body))
+			})))
+		return srv.URL()
+	}
+	down := httptest.NewServer(http.NotFoundHandler())
+	unreachable, _ := url.Parse(down.URL)
+	down.Close()
+
+	tests := []struct {
+		name          string
+		opts          []func(*Transport)
+		password      string
+		reachable     bool
+		authenticated bool
+	}{
+		{"healthy", []func(*Transport){WithBaseURL(srv.URL())}, "password", true, true},
+		{"unauthenticated", []func(*Transport){WithBaseURL(srv.URL())}, "wrong", true, false},
+		{"challenged", []func(*Transport){WithBaseURL(page("text/html", challengePage))}, "password", true, false},
+		{"non JSON", []func(*Transport){WithBaseURL(page("text/plain", "service is up"))}, "password", true, false},
+		{"unreachable", []func(*Transport){WithBaseURL(unreachable), WithRetry(RetryPolicy{MaxAttempts: 1})}, "password", false, false},
+	}
+	for _, tt := range tests {
+		c := NewClient(New("login", tt.password, tt.opts...))
+		report, err := c.Health(context.Background())
+		if (err == nil) != (tt.reachable && tt.authenticated) {
+			t.Errorf("%s: got error %v", tt.name, err)
+		}
+		if report.Reachable != tt.reachable || report.Authenticated != tt.authenticated {
+			t.Errorf("%s: got report %+v, want reachable %v and authenticated %v",
+				tt.name, report, tt.reachable, tt.authenticated)
+		}
+		if report.Latency <= 0 {
+			t.Errorf("%s: got latency %s, want measured one", tt.name, report.Latency)
+		}
+	}
+}
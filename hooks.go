@@ -0,0 +1,60 @@
+package comagic
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// RequestEditorFn edits request right before it is sent, e.g. signs it
+type RequestEditorFn func(ctx context.Context, req *http.Request) error
+
+// editorsKey is a context key of per request editors
+type editorsKey struct{}
+
+// WithRequestHook is an option function adding hook called right before
+// request is sent, including retries and replays after session renewal. Hooks are called
+// in order they were added after User-Agent, default parameters and
+// session key are set, so hook sees request exactly as it is sent.
+// Error returned by hook aborts round trip
+func WithRequestHook(hook func(*http.Request) error) func(*Transport) {
+	return func(t *Transport) { t.requestHooks = append(t.requestHooks, hook) }
+}
+
+// WithRequestEditor is an option function adding editor applied to every request
+// the same way as request hook, editors of request context run after it
+func WithRequestEditor(fn RequestEditorFn) func(*Transport) {
+	return WithRequestHook(func(r *http.Request) error { return fn(r.Context(), r) })
+}
+
+// WithRequestEditors returns context with editors applied to requests made
+// with it after editors and hooks of the transport, in order they are given
+func WithRequestEditors(ctx context.Context, fns ...RequestEditorFn) context.Context {
+	prev, _ := ctx.Value(editorsKey{}).([]RequestEditorFn)
+	editors := append(append([]RequestEditorFn(nil), prev...), fns...)
+	return context.WithValue(ctx, editorsKey{}, editors)
+}
+
+// WithResponseHook is an option function adding hook called right after
+// response is received, before expired session check. Hooks are called
+// in order they were added. Error returned by hook aborts round trip
+// and response body is closed
+func WithResponseHook(hook func(*http.Response) error) func(*Transport) {
+	return func(t *Transport) { t.responseHooks = append(t.responseHooks, hook) }
+}
+
+// editRequest applies request hooks and editors of request context
+func (t *Transport) editRequest(r *http.Request) error {
+	for _, hook := range t.requestHooks {
+		if err := hook(r); err != nil {
+			return fmt.Errorf("request hook: %w", err)
+		}
+	}
+	editors, _ := r.Context().Value(editorsKey{}).([]RequestEditorFn)
+	for _, fn := range editors {
+		if err := fn(r.Context(), r); err != nil {
+			return fmt.Errorf("request editor: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package comagic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/nk2ge5k/go-api-comagic/comagictest"
+)
+
+// closeRecorder records whether body is closed
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (b *closeRecorder) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestHooks(t *testing.T) {
+	var logins atomic.Int32
+	var calls []string
+	c := New("login", "password", WithTransport(loginStub(&logins, 0)),
+		WithRequestHook(func(r *http.Request) error {
+			calls = append(calls, "request 1 "+r.URL.Query().Get("session_key"))
+			return nil
+		}),
+		WithRequestHook(func(r *http.Request) error {
+			calls = append(calls, "request 2")
+			return nil
+		}),
+		WithResponseHook(func(res *http.Response) error {
+			calls = append(calls, "response 1")
+			return nil
+		}),
+		WithResponseHook(func(res *http.Response) error {
+			calls = append(calls, "response 2")
+			return nil
+		}),
+	)
+	req, _ := http.NewRequest(http.MethodGet, "/api/x/", nil)
+	res, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	res.Body.Close()
+	want := "request 1 key-1,request 2,response 1,response 2"
+	if got := strings.Join(calls, ","); got != want {
+		t.Errorf("got hook calls %q, want %q", got, want)
+	}
+}
+
+func TestRequestHookAbort(t *testing.T) {
+	errHook := errors.New("signing failed")
+	var requests atomic.Int32
+	c := New("login", "password", WithTransport(sequenceStub(&requests, nil, http.StatusOK)),
+		WithRequestHook(func(r *http.Request) error { return errHook }),
+		WithRequestHook(func(r *http.Request) error {
+			t.Error("hook after failed one is called")
+			return nil
+		}))
+	req, _ := http.NewRequest(http.MethodGet, "/api/x/", nil)
+	if _, err := c.Do(req); !errors.Is(err, errHook) {
+		t.Fatalf("got error %v, want hook error", err)
+	}
+	if n := requests.Load(); n != 0 {
+		t.Errorf("got %d requests sent, want round trip aborted", n)
+	}
+}
+
+func TestRequestEditors(t *testing.T) {
+	var logins atomic.Int32
+	var signatures []string
+	stub := loginStub(&logins, 0)
+	editor := func(name string) RequestEditorFn {
+		return func(ctx context.Context, r *http.Request) error {
+			r.Header.Add("X-Signature", name)
+			return nil
+		}
+	}
+	c := NewClient(New("login", "password", WithRequestEditor(editor("global")),
+		WithTransport(roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			if r.URL.Path == "/api/sites/" {
+				signatures = append(signatures, strings.Join(r.Header.Values("X-Signature"), ","))
+			}
+			return stub(r)
+		}))))
+	ctx := WithRequestEditors(context.Background(), editor("call 1"))
+	ctx = WithRequestEditors(ctx, editor("call 2"))
+	if _, err := c.Sites(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := c.Sites(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "[global,call 1,call 2 global]"
+	if got := fmt.Sprint(signatures); got != want {
+		t.Errorf("got signatures %q, want %q", got, want)
+	}
+
+	errEditor := errors.New("signing failed")
+	ctx = WithRequestEditors(context.Background(), func(context.Context, *http.Request) error { return errEditor })
+	if _, err := c.Sites(ctx); !errors.Is(err, errEditor) {
+		t.Fatalf("got error %v, want editor error", err)
+	}
+	if len(signatures) != 2 {
+		t.Errorf("got %d requests sent, want round trip aborted by editor", len(signatures)-2)
+	}
+}
+
+func TestResponseHookAbort(t *testing.T) {
+	errHook := errors.New("unexpected response")
+	var body *closeRecorder
+	stub := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		res := stubResponse(r, http.StatusOK, `{"success":true,"data":{"session_key":"key"}}`)
+		if !strings.HasSuffix(r.URL.Path, DefaultLoginPath) {
+			body = &closeRecorder{Reader: res.Body}
+			res.Body = body
+		}
+		return res, nil
+	})
+	c := New("login", "password", WithTransport(stub),
+		WithResponseHook(func(res *http.Response) error { return errHook }))
+	req, _ := http.NewRequest(http.MethodGet, "/api/x/", nil)
+	res, err := c.Do(req)
+	if !errors.Is(err, errHook) || res != nil {
+		t.Fatalf("got response %v and error %v, want hook error", res, err)
+	}
+	if body == nil || !body.closed {
+		t.Error("response body is not closed after hook error")
+	}
+}
+
+func TestAttemptFromContext(t *testing.T) {
+	var requests atomic.Int32
+	srv := comagictest.NewServer(t, comagictest.WithHandler("/api/x/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		comagictest.Respond(w, nil)
+	})))
+	var hooked, sent []int
+	record := func(attempts *[]int, r *http.Request) {
+		if r.URL.Path == "/api/x/" {
+			*attempts = append(*attempts, AttemptFromContext(r.Context()))
+		}
+	}
+	c := New("login", "password", WithBaseURL(srv.URL()), WithRetry(RetryPolicy{MaxAttempts: 2}),
+		WithTransport(roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			record(&sent, r)
+			return http.DefaultTransport.RoundTrip(r)
+		})),
+		WithRequestHook(func(r *http.Request) error {
+			record(&hooked, r)
+			return nil
+		}))
+	get := func() {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, "/api/x/", nil)
+		if n := AttemptFromContext(req.Context()); n != 0 {
+			t.Fatalf("got attempt %d of request not sent yet, want 0", n)
+		}
+		res, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		res.Body.Close()
+	}
+	get()
+	// retried once
+	get()
+	// replayed with renewed session
+	srv.ExpireSession()
+	get()
+	if want := []int{1, 1, 2, 1, 2}; fmt.Sprint(sent) != fmt.Sprint(want) {
+		t.Errorf("got attempts of sent requests %v, want %v", sent, want)
+	}
+	// hooks run before every attempt, retries included
+	if want := []int{1, 1, 2, 1, 2}; fmt.Sprint(hooked) != fmt.Sprint(want) {
+		t.Errorf("got attempts seen by request hook %v, want %v", hooked, want)
+	}
+}
@@ -0,0 +1,43 @@
+package comagic
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net/http"
+)
+
+// IdempotencyHeader is a header carrying idempotency key of the request
+const IdempotencyHeader = "Idempotency-Key"
+
+// WithIdempotency is an option function enabling idempotency keys. Every request
+// without Idempotency-Key header gets a random UUID one, the same key is sent with
+// all retries and replays of the request so server could dedupe them.
+// Requests with idempotency key are retried by retry policy regardless of method
+func WithIdempotency(enabled bool) func(*Transport) {
+	return func(t *Transport) { t.idempotency = enabled }
+}
+
+// setIdempotencyKey attaches a new idempotency key to the request
+// unless it already has one
+func setIdempotencyKey(r *http.Request) error {
+	if len(r.Header.Get(IdempotencyHeader)) > 0 {
+		return nil
+	}
+	key, err := newUUID()
+	if err != nil {
+		return err
+	}
+	r.Header.Set(IdempotencyHeader, key)
+	return nil
+}
+
+// newUUID returns random UUID version 4
+func newUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
@@ -0,0 +1,85 @@
+package comagic
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/nk2ge5k/go-api-comagic/comagictest"
+)
+
+func TestIdempotencyKey(t *testing.T) {
+	var mu sync.Mutex
+	var keys []string
+	srv := comagictest.NewServer(t, comagictest.WithHandler("/api/x/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		keys = append(keys, r.Header.Get(IdempotencyHeader))
+		n := len(keys)
+		mu.Unlock()
+		if n%2 == 1 {
+			// every request fails once
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		comagictest.Respond(w, nil)
+	})))
+	c := New("login", "password", WithBaseURL(srv.URL()),
+		WithIdempotency(true), WithRetry(RetryPolicy{MaxAttempts: 2}))
+
+	// the same request is sent twice
+	req, _ := http.NewRequest(http.MethodPost, "/api/x/", nil)
+	for i := 0; i < 2; i++ {
+		res, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("request %d: unexpected error: %v", i, err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("request %d: got status %d, want 200", i, res.StatusCode)
+		}
+	}
+	if len(keys) != 4 {
+		t.Fatalf("got %d requests, want 4", len(keys))
+	}
+	if len(keys[0]) == 0 || keys[0] != keys[1] {
+		t.Errorf("got keys %q and %q of retried request, want the same one", keys[0], keys[1])
+	}
+	if keys[2] != keys[3] {
+		t.Errorf("got keys %q and %q of retried request, want the same one", keys[2], keys[3])
+	}
+	if keys[0] == keys[2] {
+		t.Errorf("request sent again reused idempotency key %q", keys[0])
+	}
+	if len(req.Header.Get(IdempotencyHeader)) > 0 {
+		t.Error("transport set idempotency key on the caller's request")
+	}
+}
+
+func TestIdempotencyKeyOfCaller(t *testing.T) {
+	srv := comagictest.NewServer(t, comagictest.WithHandler("/api/x/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get(IdempotencyHeader); got != "caller-key" {
+			t.Errorf("got idempotency key %q, want key set by the caller", got)
+		}
+		comagictest.Respond(w, nil)
+	})))
+	c := New("login", "password", WithBaseURL(srv.URL()), WithIdempotency(true))
+
+	req, _ := http.NewRequest(http.MethodPost, "/api/x/", strings.NewReader(`{}`))
+	req.Header.Set(IdempotencyHeader, "caller-key")
+	res, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	res.Body.Close()
+}
+
+func TestNewUUID(t *testing.T) {
+	id, err := newUUID()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(id) != 36 || id[14] != '4' || strings.Count(id, "-") != 4 {
+		t.Fatalf("got %q, want UUID version 4", id)
+	}
+}
@@ -0,0 +1,51 @@
+package comagic
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// NewWithContext is like New, but ties client to the given lifecycle context.
+// When context is done authorization in progress and background refresher are
+// stopped and all subsequent requests fail immediately with the context error.
+// Lifecycle context complements request context, both are honored
+func NewWithContext(ctx context.Context, login, password string, opts ...func(*Transport)) *http.Client {
+	return New(login, password, append([]func(*Transport){
+		func(t *Transport) { t.lifecycle = ctx },
+	}, opts...)...)
+}
+
+// alive returns error if lifecycle context is done
+func (t *Transport) alive() error {
+	if t.lifecycle == nil {
+		return nil
+	}
+	if err := context.Cause(t.lifecycle); err != nil {
+		return fmt.Errorf("client is stopped: %w", err)
+	}
+	return nil
+}
+
+// background returns lifecycle context or context.Background if not set
+func (t *Transport) background() context.Context {
+	if t.lifecycle == nil {
+		return context.Background()
+	}
+	return t.lifecycle
+}
+
+// bind returns context cancelled either with ctx or with lifecycle context
+func (t *Transport) bind(ctx context.Context) (context.Context, context.CancelFunc) {
+	if t.lifecycle == nil {
+		return ctx, func() {}
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	stop := context.AfterFunc(t.lifecycle, func() {
+		cancel(context.Cause(t.lifecycle))
+	})
+	return ctx, func() {
+		stop()
+		cancel(context.Canceled)
+	}
+}
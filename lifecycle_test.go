@@ -0,0 +1,63 @@
+package comagic
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestLifecycleContext(t *testing.T) {
+	h := newFakeHost(t)
+	h.loginDelay = 500 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	c := NewWithContext(ctx, "login", "password", WithBaseURL(h.URL()))
+
+	done := make(chan error, 1)
+	go func() {
+		req, _ := http.NewRequest(http.MethodGet, "/api/x/", nil)
+		res, err := c.Do(req)
+		if err == nil {
+			res.Body.Close()
+		}
+		done <- err
+	}()
+	for len(h.Requests()) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	start := time.Now()
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("got error %v of authorization in progress, want context canceled", err)
+	}
+	if d := time.Since(start); d > 200*time.Millisecond {
+		t.Fatalf("authorization was stopped in %s", d)
+	}
+
+	requests := len(h.Requests())
+	req, _ := http.NewRequest(http.MethodGet, "/api/x/", nil)
+	if _, err := c.Do(req); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got error %v after lifecycle end, want context canceled", err)
+	}
+	if n := len(h.Requests()); n != requests {
+		t.Errorf("got %d requests sent after lifecycle end", n-requests)
+	}
+}
+
+func TestLifecycleContextRequest(t *testing.T) {
+	h := newFakeHost(t)
+	c := NewWithContext(context.Background(), "login", "password", WithBaseURL(h.URL()))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "/api/x/", nil)
+	if _, err := c.Do(req); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got error %v, want request context honored", err)
+	}
+	req, _ = http.NewRequest(http.MethodGet, "/api/x/", nil)
+	res, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	res.Body.Close()
+}
@@ -0,0 +1,37 @@
+package comagic
+
+import (
+	"context"
+	"fmt"
+)
+
+// Limiter throttles outgoing requests, it is satisfied by *rate.Limiter
+// from golang.org/x/time/rate
+type Limiter interface {
+	// Wait blocks until request is allowed or context is done
+	Wait(ctx context.Context) error
+}
+
+// WithRateLimiter is an option function for setting limiter which is waited
+// before every outgoing request including authorization and retries
+func WithRateLimiter(l Limiter) func(*Transport) {
+	return func(t *Transport) { t.limiter = l }
+}
+
+// wait waits for limiter permission if limiter is set, context error is
+// returned even if limiter does not respect context and granted permission
+func (t *Transport) wait(ctx context.Context) error {
+	if t.limiter == nil {
+		return ctx.Err()
+	}
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("rate limiter: %w", err)
+	}
+	if err := t.limiter.Wait(ctx); err != nil {
+		return fmt.Errorf("rate limiter: %w", err)
+	}
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("rate limiter: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package comagic
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// intervalLimiter allows one request per interval like rate.Limiter
+// with burst of one
+type intervalLimiter struct {
+	interval time.Duration
+
+	mu    sync.Mutex
+	next  time.Time
+	waits int
+}
+
+func (l *intervalLimiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	at := l.next
+	if at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(l.interval)
+	l.waits++
+	l.mu.Unlock()
+	return sleep(ctx, at.Sub(now))
+}
+
+func TestRateLimiter(t *testing.T) {
+	var requests atomic.Int32
+	l := &intervalLimiter{interval: time.Second}
+	c := New("", "", WithToken("token"), WithRateLimiter(l), WithTransport(sequenceStub(&requests, nil, http.StatusOK)))
+	var sent []time.Time
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest(http.MethodGet, "/api/x/", nil)
+		res, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		res.Body.Close()
+		sent = append(sent, time.Now())
+	}
+	if d := sent[1].Sub(sent[0]); d < 900*time.Millisecond {
+		t.Fatalf("second request is sent %s after the first one, want it delayed by limiter", d)
+	}
+}
+
+func TestRateLimiterLogin(t *testing.T) {
+	var logins atomic.Int32
+	l := &intervalLimiter{}
+	c := New("login", "password", WithRateLimiter(l), WithTransport(loginStub(&logins, 0)))
+	req, _ := http.NewRequest(http.MethodGet, "/api/x/", nil)
+	res, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	res.Body.Close()
+	if l.waits != 2 {
+		t.Errorf("got %d limiter waits, want login and request to wait", l.waits)
+	}
+}
+
+func TestRateLimiterContext(t *testing.T) {
+	var requests atomic.Int32
+	l := &intervalLimiter{interval: time.Hour}
+	c := New("", "", WithToken("token"), WithRateLimiter(l), WithTransport(sequenceStub(&requests, nil, http.StatusOK)))
+	req, _ := http.NewRequest(http.MethodGet, "/api/x/", nil)
+	res, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	res.Body.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	req, _ = http.NewRequestWithContext(ctx, http.MethodGet, "/api/x/", nil)
+	_, err = c.Do(req)
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "rate limiter") {
+		t.Fatalf("got error %v, want rate limiter context error", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("request failed after %s, want it to fail fast", d)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("got %d requests sent, want throttled one not sent", n)
+	}
+}
@@ -0,0 +1,43 @@
+package comagic
+
+import (
+	"context"
+	"sync"
+)
+
+// sessionLock is a mutex which could be waited for until context is done,
+// so request with short deadline does not wait for authorization made
+// by another request. Zero value is an unlocked mutex
+type sessionLock struct {
+	once sync.Once
+	ch   chan struct{}
+}
+
+func (l *sessionLock) init() {
+	l.once.Do(func() { l.ch = make(chan struct{}, 1) })
+}
+
+// Lock locks l waiting for it as long as needed
+func (l *sessionLock) Lock() {
+	l.init()
+	l.ch <- struct{}{}
+}
+
+// LockContext locks l or returns context error if context is done first
+func (l *sessionLock) LockContext(ctx context.Context) error {
+	l.init()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	select {
+	case l.ch <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Unlock unlocks l
+func (l *sessionLock) Unlock() {
+	<-l.ch
+}
@@ -0,0 +1,100 @@
+package comagic
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// redactedPrefix is a number of secret characters left visible in logs
+const redactedPrefix = 4
+
+// WithLogger is an option function for setting logger receiving debug events
+// about authorization, session reuse and retries. Password is never logged
+// and session key is logged only by its prefix
+func WithLogger(l *slog.Logger) func(*Transport) {
+	return func(t *Transport) { t.logger = l }
+}
+
+// redact hides all but a short prefix of the secret
+func redact(secret string) string {
+	if len(secret) <= redactedPrefix {
+		return "***"
+	}
+	return secret[:redactedPrefix] + "***"
+}
+
+// secretParams are query parameters holding secrets
+var secretParams = []string{"session_key", "access_token"}
+
+// RedactURL returns URL with session_key and access_token query parameters
+// masked as "***", so it is safe to log
+func RedactURL(u *url.URL) string {
+	return redactParams(u, secretParams)
+}
+
+// redactURL is like RedactURL but also masks session key
+// sent in query parameter set by WithSessionParam
+func (t *Transport) redactURL(u *url.URL) string {
+	if len(t.sessionParam) == 0 {
+		return RedactURL(u)
+	}
+	return redactParams(u, append([]string{t.sessionParam}, secretParams...))
+}
+
+// redactParams returns URL with given query parameters masked
+func redactParams(u *url.URL, params []string) string {
+	v := u.Query()
+	var masked []string
+	for _, p := range params {
+		if _, ok := v[p]; ok {
+			v.Del(p)
+			masked = append(masked, p+"=***")
+		}
+	}
+	if len(masked) == 0 {
+		return u.String()
+	}
+	q := v.Encode()
+	for _, m := range masked {
+		if len(q) > 0 {
+			q += "&"
+		}
+		q += m
+	}
+	c := *u
+	c.RawQuery = q
+	return c.String()
+}
+
+func (t *Transport) logAuth(ctx context.Context, d time.Duration, err error) {
+	attrs := []slog.Attr{slog.String("event", "auth"), slog.Duration("duration", d)}
+	if err != nil {
+		ae := &AuthError{}
+		if errors.As(err, &ae) && ae.StatusCode > 0 {
+			attrs = append(attrs, slog.Int("status", ae.StatusCode))
+		}
+		attrs = append(attrs, slog.String("error", err.Error()))
+	} else {
+		attrs = append(attrs, slog.String("session", redact(t.session.key)))
+	}
+	t.log(ctx, slog.LevelDebug, "authorization", attrs...)
+}
+
+func (t *Transport) logRetry(r *http.Request, n int, res *http.Response, err error, wait time.Duration) {
+	attrs := []slog.Attr{
+		slog.String("event", "retry"),
+		slog.Int("attempt", n),
+		slog.Duration("wait", wait),
+	}
+	if res != nil {
+		attrs = append(attrs, slog.Int("status", res.StatusCode))
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	t.log(r.Context(), slog.LevelDebug, "retrying request", attrs...)
+}
@@ -0,0 +1,150 @@
+package comagic
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nk2ge5k/go-api-comagic/comagictest"
+)
+
+func TestRedactURL(t *testing.T) {
+	tests := []struct {
+		url, want string
+	}{
+		{"https://api.comagic.ru/api/x/?session_key=secret", "https://api.comagic.ru/api/x/?session_key=***"},
+		{"https://api.comagic.ru/api/x/?a=1&session_key=secret", "https://api.comagic.ru/api/x/?a=1&session_key=***"},
+		{"https://api.comagic.ru/api/x/?access_token=secret&b=2", "https://api.comagic.ru/api/x/?b=2&access_token=***"},
+		{"https://api.comagic.ru/api/x/?a=1", "https://api.comagic.ru/api/x/?a=1"},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.url)
+		if got := RedactURL(u); got != tt.want {
+			t.Errorf("RedactURL(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestRedactSessionParam(t *testing.T) {
+	tr := New("login", "password", WithSessionParam("token")).Transport.(*Transport)
+	u, _ := url.Parse("https://api.comagic.ru/api/x/?token=secret")
+	if got, want := tr.redactURL(u), "https://api.comagic.ru/api/x/?token=***"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestResponseRequestURL(t *testing.T) {
+	srv := comagictest.NewServer(t, comagictest.WithData("/api/x/", nil))
+	for _, timeout := range []time.Duration{0, time.Second} {
+		c := New("login", "password", WithBaseURL(srv.URL()), WithClientTimeout(timeout))
+		req, _ := http.NewRequest(http.MethodGet, "/api/x", nil)
+		res, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		res.Body.Close()
+		if req.URL.String() != "/api/x" {
+			t.Errorf("timeout %s: transport changed request URL to %q", timeout, req.URL)
+		}
+		want := srv.URL().String() + "/api/x/?session_key=***"
+		if got := RedactURL(res.Request.URL); got != want {
+			t.Errorf("timeout %s: got sent URL %q, want %q", timeout, got, want)
+		}
+	}
+}
+
+// recordLogs returns logger writing JSON records into returned function
+// result, one decoded record per line
+func recordLogs(t *testing.T) (*slog.Logger, func() []map[string]interface{}) {
+	t.Helper()
+	var mu sync.Mutex
+	buf := &bytes.Buffer{}
+	l := slog.New(slog.NewJSONHandler(lockedWriter{&mu, buf}, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	return l, func() []map[string]interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+		var records []map[string]interface{}
+		for _, line := range bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n")) {
+			rec := map[string]interface{}{}
+			if err := json.Unmarshal(line, &rec); err != nil {
+				t.Fatalf("invalid log record %q: %v", line, err)
+			}
+			records = append(records, rec)
+		}
+		return records
+	}
+}
+
+type lockedWriter struct {
+	mu *sync.Mutex
+	w  io.Writer
+}
+
+func (w lockedWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Write(b)
+}
+
+func TestLogger(t *testing.T) {
+	var requests atomic.Int32
+	srv := comagictest.NewServer(t, comagictest.WithHandler("/api/x/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		comagictest.Respond(w, nil)
+	})))
+	l, records := recordLogs(t)
+	c := New("login", "secret-password", WithBaseURL(srv.URL()), WithLogger(l), WithRetry(RetryPolicy{MaxAttempts: 2}))
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest(http.MethodGet, "/api/x/", nil)
+		res, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		res.Body.Close()
+	}
+
+	events := map[string]map[string]interface{}{}
+	for _, rec := range records() {
+		if ev, ok := rec["event"].(string); ok {
+			events[ev] = rec
+		}
+		for k, v := range rec {
+			if s := fmt.Sprint(v); strings.Contains(s, "secret-password") || strings.Contains(s, comagictest.SessionKey) {
+				t.Errorf("log record leaks secret in %s: %q", k, s)
+			}
+		}
+	}
+	auth, ok := events["auth"]
+	if !ok {
+		t.Fatalf("got events %v, want auth", events)
+	}
+	if _, ok := auth["duration"]; !ok || auth["session"] != redact(comagictest.SessionKey) {
+		t.Errorf("got auth record %v, want duration and redacted session", auth)
+	}
+	if retry, ok := events["retry"]; !ok || retry["status"] != float64(http.StatusServiceUnavailable) {
+		t.Errorf("got retry record %v, want 503 status", retry)
+	}
+	if _, ok := events["session_reuse"]; !ok {
+		t.Errorf("got events %v, want session_reuse", events)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	for secret, want := range map[string]string{"": "***", "abcd": "***", "abcdef": "abcd***"} {
+		if got := redact(secret); got != want {
+			t.Errorf("redact(%q) = %q, want %q", secret, got, want)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package comagic
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"strings"
+)
+
+// LoginEncoding is an encoding of login request body
+type LoginEncoding int
+
+// Supported login encodings
+const (
+	// LoginMultipart sends credentials as multipart/form-data, it is the default.
+	// Values are sent as is, so credentials with new lines, quotes
+	// or non-ASCII characters are received by API byte to byte
+	LoginMultipart LoginEncoding = iota
+	// LoginJSON sends credentials as JSON object
+	LoginJSON
+)
+
+// WithLoginEncoding is an option function for setting login request body encoding
+func WithLoginEncoding(enc LoginEncoding) func(*Transport) {
+	return func(t *Transport) { t.loginEncoding = enc }
+}
+
+// loginBody returns encoded login request body and its content type
+func (t *Transport) loginBody(login, password string) ([]byte, string, error) {
+	loginField, passwordField := t.credentialFields()
+	if t.loginEncoding == LoginJSON {
+		body, err := json.Marshal(map[string]string{
+			loginField:    login,
+			passwordField: password,
+		})
+		return body, "application/json", err
+	}
+	buf := bytes.NewBuffer(nil)
+	var out io.Writer = buf
+	if t.loginWriter != nil {
+		out = t.loginWriter(buf)
+	}
+	w := multipart.NewWriter(out)
+	// random boundary could only be found in the credentials by chance,
+	// but then body would be cut at it and API would receive other password
+	for strings.Contains(login, w.Boundary()) || strings.Contains(password, w.Boundary()) {
+		w = multipart.NewWriter(out)
+	}
+	if err := writeCredentials(w, loginField, login, passwordField, password); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), w.FormDataContentType(), nil
+}
+
+// writeCredentials writes credentials fields into multipart writer and closes it
+func writeCredentials(w *multipart.Writer, loginField, login, passwordField, password string) error {
+	if err := w.WriteField(loginField, login); err != nil {
+		return err
+	}
+	if err := w.WriteField(passwordField, password); err != nil {
+		return err
+	}
+	return w.Close()
+}
@@ -0,0 +1,172 @@
+package comagic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestLoginPathAndFields(t *testing.T) {
+	var path, user, pass, login, password string
+	base := newLoginServer(t, func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Errorf("invalid multipart body: %v", err)
+		}
+		user, pass = r.FormValue("user"), r.FormValue("pass")
+		login, password = r.FormValue("login"), r.FormValue("password")
+		w.Write([]byte(`{"success":true,"data":{"session_key":"key"}}`))
+	})
+	tr := New("me", "secret", WithBaseURL(base),
+		WithLoginPath("/auth/signin/"), WithCredentialFields("user", "pass")).Transport.(*Transport)
+	if err := tr.Authenticate(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if path != "/auth/signin/" {
+		t.Errorf("got login path %q, want /auth/signin/", path)
+	}
+	if user != "me" || pass != "secret" || len(login) > 0 || len(password) > 0 {
+		t.Errorf("got fields user=%q pass=%q login=%q password=%q, want credentials in overridden fields",
+			user, pass, login, password)
+	}
+}
+
+func TestLoginDefaults(t *testing.T) {
+	tr := New("login", "password", WithCredentialFields("", "")).Transport.(*Transport)
+	if got := tr.loginPath(); got != DefaultLoginPath {
+		t.Errorf("got login path %q, want %q", got, DefaultLoginPath)
+	}
+	if login, password := tr.credentialFields(); login != DefaultLoginField || password != DefaultPasswordField {
+		t.Errorf("got fields %q and %q, want defaults", login, password)
+	}
+}
+
+func TestLoginEncoding(t *testing.T) {
+	for _, tt := range []struct {
+		enc         LoginEncoding
+		contentType string
+	}{
+		{LoginMultipart, "multipart/form-data"},
+		{LoginJSON, "application/json"},
+	} {
+		var contentType string
+		creds := map[string]string{}
+		base := newLoginServer(t, func(w http.ResponseWriter, r *http.Request) {
+			contentType, _, _ = mime.ParseMediaType(r.Header.Get("Content-Type"))
+			switch contentType {
+			case "application/json":
+				if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
+					t.Errorf("invalid JSON body: %v", err)
+				}
+			default:
+				r.ParseMultipartForm(1 << 20)
+				creds["login"], creds["password"] = r.FormValue("login"), r.FormValue("password")
+			}
+			w.Write([]byte(`{"success":true,"data":{"session_key":"key"}}`))
+		})
+		tr := New("me", `pa"ss`, WithBaseURL(base), WithLoginEncoding(tt.enc)).Transport.(*Transport)
+		if err := tr.Authenticate(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if contentType != tt.contentType {
+			t.Errorf("encoding %d: got content type %q, want %q", tt.enc, contentType, tt.contentType)
+		}
+		if creds["login"] != "me" || creds["password"] != `pa"ss` {
+			t.Errorf("encoding %d: got credentials %v", tt.enc, creds)
+		}
+		if tr.SessionKey() != "key" {
+			t.Errorf("encoding %d: got session key %q, want key", tt.enc, tr.SessionKey())
+		}
+	}
+}
+
+func TestLoginCredentialFidelity(t *testing.T) {
+	passwords := []string{
+		"line\nbreak\r\nand\rreturn",
+		`quo"te'd \"back\slash\"`,
+		"пароль-密码-🔑",
+		" padded\t ",
+		"--boundary-like\r\n--",
+		strings.Repeat("long=&%+", 4096),
+	}
+	for _, enc := range []LoginEncoding{LoginMultipart, LoginJSON} {
+		for _, password := range passwords {
+			var got string
+			base := newLoginServer(t, func(w http.ResponseWriter, r *http.Request) {
+				if enc == LoginJSON {
+					creds := map[string]string{}
+					if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
+						t.Errorf("invalid JSON body: %v", err)
+					}
+					got = creds["password"]
+				} else {
+					if err := r.ParseMultipartForm(1 << 20); err != nil {
+						t.Errorf("invalid multipart body: %v", err)
+					}
+					got = r.FormValue("password")
+				}
+				w.Write([]byte(`{"success":true,"data":{"session_key":"key"}}`))
+			})
+			tr := New("login", password, WithBaseURL(base), WithLoginEncoding(enc)).Transport.(*Transport)
+			if err := tr.Authenticate(context.Background()); err != nil {
+				t.Fatalf("encoding %d: unexpected error: %v", enc, err)
+			}
+			if got != password {
+				t.Errorf("encoding %d: got password %q, want %q", enc, snippet([]byte(got)), snippet([]byte(password)))
+			}
+		}
+	}
+}
+
+// failingWriter fails writes once n bytes are written
+type failingWriter struct {
+	n int
+}
+
+var errWrite = errors.New("write failed")
+
+func (w *failingWriter) Write(b []byte) (int, error) {
+	if len(b) > w.n {
+		n := w.n
+		w.n = 0
+		return n, errWrite
+	}
+	w.n -= len(b)
+	return len(b), nil
+}
+
+func TestWriteCredentialsError(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := writeCredentials(multipart.NewWriter(buf), "login", "user", "password", "secret"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// fail at the start, in the middle and in the closing boundary
+	for _, n := range []int{0, buf.Len() / 2, buf.Len() - 1} {
+		err := writeCredentials(multipart.NewWriter(&failingWriter{n: n}), "login", "user", "password", "secret")
+		if !errors.Is(err, errWrite) {
+			t.Errorf("writer failing after %d bytes: got error %v, want write error", n, err)
+		}
+	}
+}
+
+func TestAuthEncodeError(t *testing.T) {
+	tr := New("login", "password", WithTransport(roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		t.Errorf("request %s is sent with credentials that could not be encoded", r.URL.Path)
+		return stubResponse(r, http.StatusOK, `{"success":true,"data":{"session_key":"key"}}`), nil
+	}))).Transport.(*Transport)
+	tr.loginWriter = func(io.Writer) io.Writer { return &failingWriter{n: 10} }
+	err := tr.Authenticate(context.Background())
+	if !errors.Is(err, errWrite) {
+		t.Fatalf("got error %v, want write error", err)
+	}
+	if !strings.HasPrefix(err.Error(), "auth: could not encode credentials:") {
+		t.Errorf("got error %q, want auth: could not encode credentials", err)
+	}
+}
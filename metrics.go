@@ -0,0 +1,56 @@
+package comagic
+
+import (
+	"net/http"
+	"time"
+)
+
+// Metrics receives transport measurements. Methods are called concurrently
+// without any locking held, so implementations must be safe for concurrent use
+type Metrics interface {
+	// IncAuth is called after every authorization request
+	IncAuth(success bool)
+	// ObserveRequest is called after every API request,
+	// status is zero if response was not received
+	ObserveRequest(status int, d time.Duration)
+}
+
+// WithMetrics is an option function for setting metrics collector
+func WithMetrics(m Metrics) func(*Transport) {
+	return func(t *Transport) { t.collector = m }
+}
+
+// NopMetrics is a Metrics implementation discarding all measurements
+type NopMetrics struct{}
+
+// IncAuth implements Metrics interface
+func (NopMetrics) IncAuth(bool) {}
+
+// ObserveRequest implements Metrics interface
+func (NopMetrics) ObserveRequest(int, time.Duration) {}
+
+func (t *Transport) metrics() Metrics {
+	if t.collector == nil {
+		return NopMetrics{}
+	}
+	return t.collector
+}
+
+// unlock releases t.mu and reports authorization results collected under it,
+// so slow collector does not hold requests waiting for session
+func (t *Transport) unlock() {
+	results := t.authResults
+	t.authResults = nil
+	t.mu.Unlock()
+	for _, ok := range results {
+		t.metrics().IncAuth(ok)
+	}
+}
+
+func (t *Transport) observe(res *http.Response, d time.Duration) {
+	status := 0
+	if res != nil {
+		status = res.StatusCode
+	}
+	t.metrics().ObserveRequest(status, d)
+}
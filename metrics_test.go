@@ -0,0 +1,97 @@
+package comagic
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nk2ge5k/go-api-comagic/comagictest"
+)
+
+type recordingMetrics struct {
+	mu       sync.Mutex
+	auths    []bool
+	statuses []int
+	block    chan struct{}
+}
+
+func (m *recordingMetrics) IncAuth(success bool) {
+	if m.block != nil {
+		<-m.block
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.auths = append(m.auths, success)
+}
+
+func (m *recordingMetrics) ObserveRequest(status int, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.statuses = append(m.statuses, status)
+}
+
+func TestMetrics(t *testing.T) {
+	srv := comagictest.NewServer(t, comagictest.WithCredentials("login", "password"), comagictest.WithData("/api/x/", nil))
+	m := &recordingMetrics{}
+
+	bad := New("login", "wrong", WithBaseURL(srv.URL()), WithMetrics(m))
+	if err := bad.Transport.(*Transport).Authenticate(context.Background()); err == nil {
+		t.Fatal("authorization with wrong password succeeded")
+	}
+	c := New("login", "password", WithBaseURL(srv.URL()), WithMetrics(m))
+	req, _ := http.NewRequest(http.MethodGet, "/api/x/", nil)
+	res, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	res.Body.Close()
+
+	if len(m.auths) != 2 || m.auths[0] || !m.auths[1] {
+		t.Errorf("got auth results %v, want [false true]", m.auths)
+	}
+	if len(m.statuses) != 1 || m.statuses[0] != http.StatusOK {
+		t.Errorf("got request statuses %v, want [200]", m.statuses)
+	}
+}
+
+func TestMetricsWithoutLock(t *testing.T) {
+	srv := comagictest.NewServer(t, comagictest.WithData("/api/x/", nil))
+	m := &recordingMetrics{block: make(chan struct{})}
+	c := New("login", "password", WithBaseURL(srv.URL()), WithMetrics(m))
+	tr := c.Transport.(*Transport)
+
+	authorized := make(chan error, 1)
+	go func() { authorized <- tr.Authenticate(context.Background()) }()
+	// wait for session while collector is blocked
+	for deadline := time.Now().Add(time.Second); len(tr.SessionKey()) == 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("session is not established")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "/api/x/", nil)
+	res, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("request waited for metrics collector: %v", err)
+	}
+	res.Body.Close()
+
+	close(m.block)
+	if err := <-authorized; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestNopMetrics(t *testing.T) {
+	var m Metrics = NopMetrics{}
+	m.IncAuth(true)
+	m.ObserveRequest(http.StatusOK, time.Second)
+	if _, ok := (&Transport{}).metrics().(NopMetrics); !ok {
+		t.Fatal("transport without collector does not use NopMetrics")
+	}
+}
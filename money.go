@@ -0,0 +1,170 @@
+package comagic
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Money is an exact decimal amount decoded from API string or number representation
+// without float rounding
+type Money struct {
+	rat   *big.Rat
+	scale int
+}
+
+// ParseMoney parses decimal amount like "12.3450"
+func ParseMoney(s string) (Money, error) {
+	s = strings.TrimSpace(s)
+	r, ok := new(big.Rat).SetString(s)
+	if !ok || strings.ContainsAny(s, "/eE") {
+		return Money{}, fmt.Errorf("invalid money amount %q", s)
+	}
+	scale := 0
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		scale = len(s) - i - 1
+	}
+	return Money{rat: r, scale: scale}, nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler interface
+func (m *Money) UnmarshalJSON(b []byte) error {
+	b = bytes.TrimSpace(b)
+	if bytes.Equal(b, []byte("null")) {
+		*m = Money{}
+		return nil
+	}
+	s := string(b)
+	if len(b) > 0 && b[0] == '"' {
+		if err := json.Unmarshal(b, &s); err != nil {
+			return err
+		}
+		if len(s) == 0 {
+			*m = Money{}
+			return nil
+		}
+	}
+	v, err := ParseMoney(s)
+	if err != nil {
+		return err
+	}
+	*m = v
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler interface, amount is encoded as string
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.String())
+}
+
+// String returns amount with the same number of decimal places it was parsed with
+func (m Money) String() string {
+	if m.rat == nil {
+		return "0"
+	}
+	return m.rat.FloatString(m.scale)
+}
+
+// Rat returns amount as a rational number
+func (m Money) Rat() *big.Rat {
+	if m.rat == nil {
+		return new(big.Rat)
+	}
+	return new(big.Rat).Set(m.rat)
+}
+
+// IsZero reports whether amount is zero
+func (m Money) IsZero() bool {
+	return m.rat == nil || m.rat.Sign() == 0
+}
+
+// Add returns sum of amounts
+func (m Money) Add(o Money) Money {
+	scale := m.scale
+	if o.scale > scale {
+		scale = o.scale
+	}
+	return Money{rat: new(big.Rat).Add(m.Rat(), o.Rat()), scale: scale}
+}
+
+// Cmp compares amounts and returns -1, 0 or +1
+func (m Money) Cmp(o Money) int {
+	return m.Rat().Cmp(o.Rat())
+}
+
+// Minor returns amount in minor units, e.g. cents for two decimal places,
+// exact is false if amount has more decimal places and was truncated
+func (m Money) Minor(places int) (units int64, exact bool) {
+	r := m.Rat()
+	r.Mul(r, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(places)), nil)))
+	q := new(big.Int).Quo(r.Num(), r.Denom())
+	return q.Int64(), r.IsInt() && q.IsInt64()
+}
+
+// Float64 returns nearest float64 value of amount, it is inexact by nature
+// and should be used only for display
+func (m Money) Float64() float64 {
+	f, _ := m.Rat().Float64()
+	return f
+}
+
+// Duration is a duration decoded from API seconds representation,
+// given either as number or string with seconds or as "hh:mm:ss"
+type Duration time.Duration
+
+// UnmarshalJSON implements json.Unmarshaler interface
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(bytes.TrimSpace(b)), `"`)
+	if len(s) == 0 || s == "null" {
+		*d = 0
+		return nil
+	}
+	if strings.Contains(s, ":") {
+		parts := strings.Split(s, ":")
+		if len(parts) != 3 {
+			return fmt.Errorf("invalid duration %q", s)
+		}
+		total := 0
+		for _, p := range parts {
+			n, err := strconv.Atoi(p)
+			if err != nil || n < 0 {
+				return fmt.Errorf("invalid duration %q", s)
+			}
+			total = total*60 + n
+		}
+		*d = Duration(time.Duration(total) * time.Second)
+		return nil
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok || r.Sign() < 0 {
+		return fmt.Errorf("invalid duration %q", s)
+	}
+	// seconds could be fractional, keep millisecond precision
+	ms := new(big.Rat).Mul(r, big.NewRat(1000, 1))
+	*d = Duration(time.Duration(new(big.Int).Quo(ms.Num(), ms.Denom()).Int64()) * time.Millisecond)
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler interface, duration is encoded in seconds
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.Seconds())
+}
+
+// Seconds returns duration in seconds
+func (d Duration) Seconds() float64 {
+	return time.Duration(d).Seconds()
+}
+
+// Duration returns value as time.Duration
+func (d Duration) Duration() time.Duration {
+	return time.Duration(d)
+}
+
+// String implements fmt.Stringer interface
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
@@ -0,0 +1,78 @@
+package comagic
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestMoney(t *testing.T) {
+	var a, b Money
+	if err := json.Unmarshal([]byte(`0.1`), &a); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := json.Unmarshal([]byte(`"0.2"`), &b); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sum := a.Add(b)
+	want, _ := ParseMoney("0.3")
+	if sum.Cmp(want) != 0 || sum.String() != "0.3" {
+		t.Fatalf("got 0.1 + 0.2 = %s, want exact 0.3", sum)
+	}
+	if units, exact := sum.Minor(2); units != 30 || !exact {
+		t.Errorf("got %d minor units (exact %v), want 30", units, exact)
+	}
+	m, _ := ParseMoney("1.005")
+	if units, exact := m.Minor(2); units != 100 || exact {
+		t.Errorf("got %d minor units (exact %v) of 1.005, want truncated 100", units, exact)
+	}
+	if m.String() != "1.005" {
+		t.Errorf("got %s, want decimal places kept", m)
+	}
+	b2, err := json.Marshal(m)
+	if err != nil || string(b2) != `"1.005"` {
+		t.Errorf("got %s (%v), want amount encoded as string", b2, err)
+	}
+	var zero Money
+	if err := json.Unmarshal([]byte(`null`), &zero); err != nil || !zero.IsZero() || zero.String() != "0" {
+		t.Errorf("got %s (%v) of null, want zero", zero, err)
+	}
+}
+
+func TestParseMoneyInvalid(t *testing.T) {
+	for _, s := range []string{"", "abc", "1/3", "1e3", "1.2.3"} {
+		if _, err := ParseMoney(s); err == nil {
+			t.Errorf("ParseMoney(%q) succeeded, want error", s)
+		}
+	}
+}
+
+func TestDuration(t *testing.T) {
+	tests := []struct {
+		json string
+		want time.Duration
+	}{
+		{`61`, 61 * time.Second},
+		{`"61"`, 61 * time.Second},
+		{`1.5`, 1500 * time.Millisecond},
+		{`"01:02:03"`, time.Hour + 2*time.Minute + 3*time.Second},
+		{`""`, 0},
+		{`null`, 0},
+	}
+	for _, tt := range tests {
+		var d Duration
+		if err := json.Unmarshal([]byte(tt.json), &d); err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.json, err)
+			continue
+		}
+		if d.Duration() != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.json, d, tt.want)
+		}
+	}
+	for _, s := range []string{`-1`, `"1:2"`, `"a:b:c"`, `"abc"`} {
+		var d Duration
+		if err := json.Unmarshal([]byte(s), &d); err == nil {
+			t.Errorf("%s: got duration %s, want error", s, d)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package comagic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// VirtualNumber is a virtual phone number of the account
+type VirtualNumber struct {
+	Number     string `json:"number"`
+	Type       string `json:"type"`
+	CampaignID int64  `json:"ac_id"`
+	SiteID     int64  `json:"site_id"`
+	Status     string `json:"status"`
+	// Extra holds response fields unknown to the package
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler interface
+func (n *VirtualNumber) UnmarshalJSON(b []byte) error {
+	type plain VirtualNumber
+	if err := json.Unmarshal(b, (*plain)(n)); err != nil {
+		return err
+	}
+	extra, err := unknownFields(b, n)
+	n.Extra = extra
+	return err
+}
+
+// VirtualNumbers returns virtual phone numbers of the account
+func (c *Client) VirtualNumbers(ctx context.Context) ([]VirtualNumber, error) {
+	numbers := []VirtualNumber{}
+	if err := c.getReference(ctx, "/api/virtual_numbers/", nil, &numbers); err != nil {
+		return nil, fmt.Errorf("virtual numbers: %w", err)
+	}
+	return numbers, nil
+}
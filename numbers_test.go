@@ -0,0 +1,53 @@
+package comagic
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/nk2ge5k/go-api-comagic/comagictest"
+)
+
+func TestVirtualNumbers(t *testing.T) {
+	srv := comagictest.NewServer(t, comagictest.WithData("/api/virtual_numbers/", []map[string]interface{}{
+		{"number": "74950000001", "type": "static", "ac_id": 7, "site_id": 2, "status": "active"},
+	}))
+	c := NewClient(New("login", "password", WithBaseURL(srv.URL())))
+	numbers, err := c.VirtualNumbers(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := VirtualNumber{Number: "74950000001", Type: "static", CampaignID: 7, SiteID: 2, Status: "active"}
+	if len(numbers) != 1 || numbers[0].Number != want.Number || numbers[0].Type != want.Type ||
+		numbers[0].CampaignID != want.CampaignID || numbers[0].SiteID != want.SiteID || numbers[0].Status != want.Status {
+		t.Fatalf("got numbers %+v, want [%+v]", numbers, want)
+	}
+}
+
+func TestVirtualNumbersEmpty(t *testing.T) {
+	srv := comagictest.NewServer(t, comagictest.WithData("/api/virtual_numbers/", []interface{}{}))
+	c := NewClient(New("login", "password", WithBaseURL(srv.URL())))
+	numbers, err := c.VirtualNumbers(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if numbers == nil || len(numbers) != 0 {
+		t.Fatalf("got numbers %v, want empty list", numbers)
+	}
+}
+
+func TestVirtualNumbersAPIError(t *testing.T) {
+	srv := comagictest.NewServer(t, comagictest.WithHandler("/api/virtual_numbers/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		comagictest.RespondError(w, "access_denied", "no access to virtual numbers")
+	})))
+	c := NewClient(New("login", "password", WithBaseURL(srv.URL())))
+	_, err := c.VirtualNumbers(context.Background())
+	ae := &APIError{}
+	if !errors.As(err, &ae) || ae.Code != "access_denied" {
+		t.Fatalf("got error %v, want access_denied APIError", err)
+	}
+	if got, want := err.Error(), "virtual numbers: api error: access_denied: no access to virtual numbers"; got != want {
+		t.Errorf("got error %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,44 @@
+package comagic
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// rawResponseKey is a context key of raw response destination
+type rawResponseKey struct{}
+
+// rawResponse is a raw response destination, requests made concurrently
+// with the same context store their responses under the lock
+type rawResponse struct {
+	mu  sync.Mutex
+	dst **http.Response
+}
+
+// store stores response into destination
+func (r *rawResponse) store(res *http.Response) {
+	r.mu.Lock()
+	*r.dst = res
+	r.mu.Unlock()
+}
+
+// WithRawResponse returns context making Client methods store raw response
+// of API request into res, so its status and headers like X-Request-Id could
+// be inspected. Response body is already drained and closed when method
+// returns. Method making several requests stores the last received response
+func WithRawResponse(ctx context.Context, res **http.Response) context.Context {
+	if res == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, rawResponseKey{}, &rawResponse{dst: res})
+}
+
+// do sends request with client doer storing raw response if requested
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	res, err := c.doer.Do(req)
+	if raw, ok := req.Context().Value(rawResponseKey{}).(*rawResponse); ok && res != nil {
+		raw.store(res)
+	}
+	return res, err
+}
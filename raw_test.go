@@ -0,0 +1,63 @@
+package comagic
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/nk2ge5k/go-api-comagic/comagictest"
+)
+
+func TestWithRawResponse(t *testing.T) {
+	srv := comagictest.NewServer(t,
+		comagictest.WithHandler("/api/sites/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Request-Id", "req-1")
+			comagictest.Respond(w, []interface{}{})
+		})),
+		comagictest.WithHandler("/api/virtual_numbers/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Request-Id", "req-2")
+			comagictest.RespondError(w, "access_denied", "no access")
+		})),
+	)
+	c := NewClient(New("login", "password", WithBaseURL(srv.URL())))
+
+	var res *http.Response
+	if _, err := c.Sites(WithRawResponse(context.Background(), &res)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res == nil || res.StatusCode != http.StatusOK || res.Header.Get("X-Request-Id") != "req-1" {
+		t.Fatalf("got raw response %+v, want one with X-Request-Id", res)
+	}
+
+	res = nil
+	_, err := c.VirtualNumbers(WithRawResponse(context.Background(), &res))
+	ae := &APIError{}
+	if !errors.As(err, &ae) {
+		t.Fatalf("got error %v, want APIError", err)
+	}
+	if res == nil || res.Header.Get("X-Request-Id") != "req-2" {
+		t.Fatalf("got raw response %+v of failed request, want one with X-Request-Id", res)
+	}
+}
+
+// TestWithRawResponseConcurrent is meant to be run with -race flag
+func TestWithRawResponseConcurrent(t *testing.T) {
+	srv := comagictest.NewServer(t, comagictest.WithHandler("/api/calls_report/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", r.URL.Query().Get("date_from"))
+		comagictest.Respond(w, []interface{}{})
+	})))
+	c := NewClient(New("login", "password", WithBaseURL(srv.URL())))
+
+	var res *http.Response
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, DefaultLocation)
+	err := c.CallsReportRange(WithRawResponse(context.Background(), &res), from, from.AddDate(0, 0, 8).Add(-time.Second), 4,
+		func(day time.Time, calls []Call) error { return nil })
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res == nil || len(res.Header.Get("X-Request-Id")) == 0 {
+		t.Fatalf("got raw response %+v, want one of the days", res)
+	}
+}
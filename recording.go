@@ -0,0 +1,38 @@
+package comagic
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// RecordingMeta describes call recording
+type RecordingMeta struct {
+	// ContentType is a media type of recording, e.g. audio/mpeg
+	ContentType string
+	// Size is a number of bytes written
+	Size int64
+	// Duration of recording, zero if API did not report it
+	Duration time.Duration
+}
+
+// CallRecording writes recording of the call into w as is, without decoding
+func (c *Client) CallRecording(ctx context.Context, callID int64, w io.Writer) (RecordingMeta, error) {
+	v := url.Values{}
+	v.Set("call_id", strconv.FormatInt(callID, 10))
+	h, n, err := c.getExport(ctx, "/api/call_record/", v, "audio/*", w)
+	if err != nil {
+		return RecordingMeta{Size: n}, fmt.Errorf("call recording: %w", err)
+	}
+	meta := RecordingMeta{ContentType: h.Get("Content-Type"), Size: n}
+	for _, name := range []string{"Content-Duration", "X-Content-Duration"} {
+		if sec, err := strconv.ParseFloat(h.Get(name), 64); err == nil && sec > 0 {
+			meta.Duration = time.Duration(sec * float64(time.Second))
+			break
+		}
+	}
+	return meta, nil
+}
@@ -0,0 +1,56 @@
+package comagic
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/nk2ge5k/go-api-comagic/comagictest"
+)
+
+func TestCallRecording(t *testing.T) {
+	audio := []byte("ID3\x04\x00")
+	for i := 0; i < 64<<10; i++ {
+		audio = append(audio, byte(i))
+	}
+	srv := comagictest.NewServer(t, comagictest.WithHandler("/api/call_record/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id := r.URL.Query().Get("call_id"); id != "10" {
+			t.Errorf("got call id %q, want 10", id)
+		}
+		w.Header().Set("Content-Type", "audio/mpeg")
+		w.Header().Set("X-Content-Duration", "12.5")
+		w.Write(audio)
+	})))
+	c := NewClient(New("login", "password", WithBaseURL(srv.URL())))
+	buf := &bytes.Buffer{}
+	meta, err := c.CallRecording(context.Background(), 10, buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), audio) {
+		t.Fatalf("got %d bytes of recording, want %d bytes unchanged", buf.Len(), len(audio))
+	}
+	want := RecordingMeta{ContentType: "audio/mpeg", Size: int64(len(audio)), Duration: 12500 * time.Millisecond}
+	if meta != want {
+		t.Errorf("got meta %+v, want %+v", meta, want)
+	}
+}
+
+func TestCallRecordingAPIError(t *testing.T) {
+	srv := comagictest.NewServer(t, comagictest.WithHandler("/api/call_record/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		comagictest.RespondError(w, "record_not_found", "call has no recording")
+	})))
+	c := NewClient(New("login", "password", WithBaseURL(srv.URL())))
+	buf := &bytes.Buffer{}
+	meta, err := c.CallRecording(context.Background(), 10, buf)
+	ae := &APIError{}
+	if !errors.As(err, &ae) || ae.Code != "record_not_found" {
+		t.Fatalf("got error %v, want record_not_found APIError", err)
+	}
+	if buf.Len() != 0 || meta.Size != 0 {
+		t.Errorf("got %d bytes written, want error envelope not copied", buf.Len())
+	}
+}
@@ -0,0 +1,65 @@
+package comagic
+
+import "time"
+
+// refreshThreshold is a part of session lifetime left
+// when background refresher renews session
+const refreshThreshold = 10
+
+// WithBackgroundRefresh is an option function enabling background goroutine
+// which checks session every interval and renews it when less than 10% of
+// session lifetime is left, so requests do not wait for authorization.
+// Refresher is stopped by Transport.Close, Client.Close or when lifecycle
+// context given to NewWithContext is done, without them
+// refresher lives for the process lifetime
+func WithBackgroundRefresh(interval time.Duration) func(*Transport) {
+	return func(t *Transport) { t.refreshInterval = interval }
+}
+
+// Close stops background refresher if any and drops sessions of credentials
+// given with request context, it is safe to call Close many times
+func (t *Transport) Close() error {
+	t.closeOnce.Do(func() {
+		if t.done != nil {
+			close(t.done)
+		}
+	})
+	t.closeTenants()
+	return nil
+}
+
+func (t *Transport) startRefresh() {
+	t.done = make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(t.refreshInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-t.done:
+				return
+			case <-t.background().Done():
+				return
+			case <-ticker.C:
+				t.refresh()
+			}
+		}
+	}()
+}
+
+// refresh renews established session if it is about to expire,
+// errors are ignored since session will be renewed on demand anyway.
+// Refresh waiting for the lock is given up once lifecycle context is done
+func (t *Transport) refresh() {
+	ctx := t.background()
+	if err := t.mu.LockContext(ctx); err != nil {
+		return
+	}
+	defer t.unlock()
+	if len(t.session.key) == 0 {
+		return
+	}
+	if t.clock().Sub(t.session.start) < t.validFor()-t.lifetime()/refreshThreshold {
+		return
+	}
+	t.renew(ctx)
+}
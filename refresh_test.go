@@ -0,0 +1,114 @@
+package comagic
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock moved forward only by the test
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// waitLogins waits until n logins are counted or fails after a second
+func waitLogins(t *testing.T, logins *atomic.Int32, n int32) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); logins.Load() < n; {
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d logins, want %d", logins.Load(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestBackgroundRefresh(t *testing.T) {
+	var logins atomic.Int32
+	clock := newFakeClock()
+	tr := New("login", "password", WithTransport(loginStub(&logins, 0)),
+		WithClock(clock.Now), WithBackgroundRefresh(time.Millisecond)).Transport.(*Transport)
+	defer tr.Close()
+	if err := tr.Authenticate(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// session is not refreshed while it is far from expiry
+	clock.Advance(time.Hour)
+	time.Sleep(20 * time.Millisecond)
+	if n := logins.Load(); n != 1 {
+		t.Fatalf("got %d logins, session is refreshed too early", n)
+	}
+
+	clock.Advance(SessionLifetime - DefaultSessionSkew - SessionLifetime/refreshThreshold - time.Hour)
+	waitLogins(t, &logins, 2)
+	if got := tr.SessionKey(); got != "key-2" {
+		t.Errorf("got session key %q, want refreshed one", got)
+	}
+	if tr.SessionExpiresAt().Before(clock.Now().Add(SessionLifetime - DefaultSessionSkew)) {
+		t.Error("refreshed session expires before its lifetime")
+	}
+}
+
+func TestBackgroundRefreshClose(t *testing.T) {
+	var logins atomic.Int32
+	clock := newFakeClock()
+	tr := New("login", "password", WithTransport(loginStub(&logins, 0)),
+		WithClock(clock.Now), WithBackgroundRefresh(time.Millisecond)).Transport.(*Transport)
+	if err := tr.Authenticate(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tr.Close()
+	tr.Close()
+	// let refresher observe Close before session is about to expire
+	time.Sleep(10 * time.Millisecond)
+	clock.Advance(SessionLifetime)
+	time.Sleep(20 * time.Millisecond)
+	if n := logins.Load(); n != 1 {
+		t.Fatalf("got %d logins, refresher is not stopped by Close", n)
+	}
+}
+
+func TestRefreshLockCancel(t *testing.T) {
+	var logins atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+	tr := NewWithContext(ctx, "login", "password", WithTransport(loginStub(&logins, 0))).Transport.(*Transport)
+	if err := tr.Authenticate(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		tr.refresh()
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("refresh keeps waiting for the lock after lifecycle context is done")
+	}
+	if n := logins.Load(); n != 1 {
+		t.Errorf("got %d logins, want no refresh after cancel", n)
+	}
+}
@@ -0,0 +1,169 @@
+package comagic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy describes how requests are retried on transient failures:
+// network errors and 429, 502, 503 and 504 responses.
+// Only idempotent requests are retried
+type RetryPolicy struct {
+	// MaxAttempts is a maximum number of attempts including the first one
+	MaxAttempts int
+	// Backoff returns delay before n-th retry, starting from 1.
+	// Retry-After header of the response takes precedence over it
+	Backoff func(n int) time.Duration
+}
+
+// WithRetry is an option function for setting retry policy
+func WithRetry(policy RetryPolicy) func(*Transport) {
+	return func(t *Transport) { t.retry = &policy }
+}
+
+// ExponentialBackoff returns backoff function doubling base delay on every retry
+// up to max with full jitter applied
+func ExponentialBackoff(base, max time.Duration) func(n int) time.Duration {
+	return func(n int) time.Duration {
+		d := max
+		if n < 32 && base<<uint(n-1) < max {
+			d = base << uint(n-1)
+		}
+		if d <= 0 {
+			return 0
+		}
+		return time.Duration(rand.Int63n(int64(d)) + 1)
+	}
+}
+
+// send sends request with underlying transport retrying transient failures,
+// request hooks are applied before every attempt
+func (t *Transport) send(r *http.Request) (*http.Response, error) {
+	p := t.retry
+	for n := 1; ; n++ {
+		if err := t.wait(r.Context()); err != nil {
+			return nil, fmt.Errorf("round trip: %w", err)
+		}
+		if n > 1 {
+			nextAttempt(r.Context())
+		}
+		if err := t.editRequest(r); err != nil {
+			return nil, fmt.Errorf("round trip: %w", err)
+		}
+		res, err := t.exchange(r)
+		if p == nil || n >= p.MaxAttempts || !idempotent(r) || errors.Is(err, ErrCircuitOpen) {
+			return res, err
+		}
+		wait, ok := retryable(r, res, err, t.clock())
+		if !ok {
+			return res, err
+		}
+		if res != nil {
+			io.Copy(io.Discard, io.LimitReader(res.Body, maxErrorSize))
+			res.Body.Close()
+		}
+		if wait <= 0 && p.Backoff != nil {
+			wait = p.Backoff(n)
+		}
+		if t.logger != nil {
+			t.logRetry(r, n, res, err, wait)
+		}
+		if err := sleep(r.Context(), wait); err != nil {
+			return nil, err
+		}
+		if err := rewind(r); err != nil {
+			return nil, fmt.Errorf("round trip: could not rewind request body: %w", err)
+		}
+	}
+}
+
+// readOnlyKey is a context key marking request as read-only whatever method it has
+type readOnlyKey struct{}
+
+// withReadOnly returns context marking request sent with it as read-only,
+// so it is retried like GET even if it is sent with POST
+func withReadOnly(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readOnlyKey{}, true)
+}
+
+// idempotent reports whether request could be safely sent more than once,
+// request with idempotency key is deduped by server whatever method it has
+// and read-only request does not change anything on the server
+func idempotent(r *http.Request) bool {
+	if readOnly, _ := r.Context().Value(readOnlyKey{}).(bool); readOnly {
+		return !hasBody(r) || r.GetBody != nil
+	}
+	if len(r.Header.Get(IdempotencyHeader)) > 0 {
+		return !hasBody(r) || r.GetBody != nil
+	}
+	switch r.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return !hasBody(r) || r.GetBody != nil
+	}
+	return false
+}
+
+// retryable reports whether request should be retried and
+// how long to wait according to Retry-After header
+func retryable(r *http.Request, res *http.Response, err error, now time.Time) (time.Duration, bool) {
+	if err != nil {
+		return 0, r.Context().Err() == nil
+	}
+	switch res.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		d, _ := parseRetryAfter(res.Header.Get("Retry-After"), now)
+		return d, true
+	}
+	return 0, false
+}
+
+// parseRetryAfter parses Retry-After header value given
+// either in seconds or as HTTP date
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	if len(v) == 0 {
+		return 0, false
+	}
+	if sec, err := strconv.Atoi(v); err == nil {
+		if sec < 0 {
+			return 0, false
+		}
+		return time.Duration(sec) * time.Second, true
+	}
+	at, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+	if d := at.Sub(now); d > 0 {
+		return d, true
+	}
+	return 0, true
+}
+
+// sleep waits for given duration or until context is done,
+// if context deadline comes earlier it fails without waiting
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < d {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return context.DeadlineExceeded
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
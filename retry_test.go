@@ -0,0 +1,175 @@
+package comagic
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// sequenceStub returns stub transport answering login and then API requests
+// with given statuses in order, the last status is repeated. API requests
+// are counted in requests and their bodies are kept in bodies
+func sequenceStub(requests *atomic.Int32, bodies *[]string, statuses ...int) roundTripFunc {
+	var logins atomic.Int32
+	return func(r *http.Request) (*http.Response, error) {
+		if strings.HasSuffix(r.URL.Path, DefaultLoginPath) {
+			return loginStub(&logins, 0)(r)
+		}
+		n := int(requests.Add(1))
+		if bodies != nil && r.Body != nil {
+			b, _ := io.ReadAll(r.Body)
+			*bodies = append(*bodies, string(b))
+		}
+		status := statuses[len(statuses)-1]
+		if n <= len(statuses) {
+			status = statuses[n-1]
+		}
+		return stubResponse(r, status, `{"success":true,"data":[]}`), nil
+	}
+}
+
+func TestRetry(t *testing.T) {
+	var requests atomic.Int32
+	var bodies []string
+	c := New("login", "password", WithRetry(RetryPolicy{MaxAttempts: 3}),
+		WithTransport(sequenceStub(&requests, &bodies, http.StatusServiceUnavailable, http.StatusOK)))
+	req, _ := http.NewRequest(http.MethodPut, "/api/x/", strings.NewReader("payload"))
+	res, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK || requests.Load() != 2 {
+		t.Fatalf("got status %d after %d requests, want 200 after retry", res.StatusCode, requests.Load())
+	}
+	if strings.Join(bodies, " ") != "payload payload" {
+		t.Errorf("got bodies %q, want body replayed", bodies)
+	}
+}
+
+func TestRetryNotRetryable(t *testing.T) {
+	for _, tt := range []struct {
+		method string
+		status int
+	}{
+		{http.MethodGet, http.StatusBadRequest},
+		{http.MethodPost, http.StatusServiceUnavailable},
+	} {
+		var requests atomic.Int32
+		c := New("login", "password", WithRetry(RetryPolicy{MaxAttempts: 3}),
+			WithTransport(sequenceStub(&requests, nil, tt.status, http.StatusOK)))
+		req, _ := http.NewRequest(tt.method, "/api/x/", nil)
+		res, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		res.Body.Close()
+		if res.StatusCode != tt.status || requests.Load() != 1 {
+			t.Errorf("%s %d: got status %d after %d requests, want no retry",
+				tt.method, tt.status, res.StatusCode, requests.Load())
+		}
+	}
+}
+
+func TestRetryMaxAttempts(t *testing.T) {
+	var requests atomic.Int32
+	var waits []int
+	c := New("login", "password",
+		WithRetry(RetryPolicy{MaxAttempts: 3, Backoff: func(n int) time.Duration {
+			waits = append(waits, n)
+			return time.Millisecond
+		}}),
+		WithTransport(sequenceStub(&requests, nil, http.StatusBadGateway)))
+	req, _ := http.NewRequest(http.MethodGet, "/api/x/", nil)
+	res, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadGateway || requests.Load() != 3 {
+		t.Errorf("got status %d after %d requests, want 502 after 3 attempts", res.StatusCode, requests.Load())
+	}
+	if len(waits) != 2 || waits[0] != 1 || waits[1] != 2 {
+		t.Errorf("got backoff calls %v, want [1 2]", waits)
+	}
+}
+
+func TestRetryNetworkError(t *testing.T) {
+	var logins, requests atomic.Int32
+	stub := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if strings.HasSuffix(r.URL.Path, DefaultLoginPath) {
+			return loginStub(&logins, 0)(r)
+		}
+		if requests.Add(1) == 1 {
+			return nil, errors.New("connection reset by peer")
+		}
+		return stubResponse(r, http.StatusOK, `{"success":true,"data":[]}`), nil
+	})
+	c := New("login", "password", WithRetry(RetryPolicy{MaxAttempts: 2}), WithTransport(stub))
+	req, _ := http.NewRequest(http.MethodGet, "/api/x/", nil)
+	res, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	res.Body.Close()
+	if requests.Load() != 2 {
+		t.Errorf("got %d requests, want network error retried", requests.Load())
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"", 0, false},
+		{"120", 2 * time.Minute, true},
+		{"-1", 0, false},
+		{now.Add(time.Minute).Format(http.TimeFormat), time.Minute, true},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0, true},
+		{"soon", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.value, now)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseRetryAfter(%q) = %s, %v, want %s, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := ExponentialBackoff(10*time.Millisecond, 50*time.Millisecond)
+	for n, max := range map[int]time.Duration{1: 10 * time.Millisecond, 2: 20 * time.Millisecond, 3: 40 * time.Millisecond, 4: 50 * time.Millisecond, 40: 50 * time.Millisecond} {
+		for i := 0; i < 100; i++ {
+			if d := backoff(n); d <= 0 || d > max {
+				t.Fatalf("backoff(%d) = %s, want in (0, %s]", n, d, max)
+			}
+		}
+	}
+}
+
+func TestRetryBackoffDeadline(t *testing.T) {
+	var requests atomic.Int32
+	c := New("", "", WithToken("token"), WithTransport(sequenceStub(&requests, nil, http.StatusServiceUnavailable)),
+		WithRetry(RetryPolicy{MaxAttempts: 5, Backoff: func(int) time.Duration { return time.Hour }}))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "/api/x/", nil)
+	if _, err := c.Do(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got error %v, want context deadline during backoff", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("request failed after %s, want it to fail at deadline", d)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("got %d requests, want retry not sent after deadline", n)
+	}
+}
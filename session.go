@@ -0,0 +1,237 @@
+package comagic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// SessionStore persists session key so it could be reused by another
+// transport or process instead of making new authorization request
+type SessionStore interface {
+	// Load returns stored session key and time session was started at,
+	// ok is false if there is no stored session
+	Load() (key string, start time.Time, ok bool)
+	// Save stores session key and time session was started at
+	Save(key string, start time.Time)
+}
+
+// WithSessionStore is an option function for setting session store.
+// Store is loaded on the first request and saved after every authorization,
+// both under the same lock as authorization request
+func WithSessionStore(s SessionStore) func(*Transport) {
+	return func(t *Transport) { t.store = s }
+}
+
+// FileSessionStore is a SessionStore keeping session in JSON file
+// readable only by its owner. Save errors are ignored since
+// failing to persist session only leads to extra authorization request
+type FileSessionStore struct {
+	Path string
+}
+
+type fileSession struct {
+	Key   string    `json:"session_key"`
+	Start time.Time `json:"start"`
+}
+
+// Load implements SessionStore interface
+func (s *FileSessionStore) Load() (string, time.Time, bool) {
+	b, err := os.ReadFile(s.Path)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	fs := fileSession{}
+	if err := json.Unmarshal(b, &fs); err != nil || len(fs.Key) == 0 {
+		return "", time.Time{}, false
+	}
+	return fs.Key, fs.Start, true
+}
+
+// Save implements SessionStore interface, file is replaced atomically
+func (s *FileSessionStore) Save(key string, start time.Time) {
+	b, err := json.Marshal(fileSession{Key: key, Start: start})
+	if err != nil {
+		return
+	}
+	// temporary file is created with 0600 permissions
+	f, err := os.CreateTemp(filepath.Dir(s.Path), filepath.Base(s.Path)+".*")
+	if err != nil {
+		return
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return
+	}
+	if err := f.Close(); err != nil {
+		return
+	}
+	os.Rename(f.Name(), s.Path)
+}
+
+// ErrAuthParamCollision is returned in strict mode if request already
+// has query parameter session key or access token is sent in
+var ErrAuthParamCollision = errors.New("request already has authorization query parameter")
+
+// WithStrictAuthParam is an option function making transport reject requests
+// which query already has parameter session key or access token is sent in
+// with ErrAuthParamCollision, by default parameter is replaced and
+// warning is logged
+func WithStrictAuthParam() func(*Transport) {
+	return func(t *Transport) { t.strictAuthParam = true }
+}
+
+// checkAuthParam checks that request has no query parameter transport
+// authorizes it with: parameter set by the caller, for example in URL
+// copied from browser, is always replaced by the transport
+func (t *Transport) checkAuthParam(r *http.Request) error {
+	param := t.authParam()
+	if len(param) == 0 || !r.URL.Query().Has(param) {
+		return nil
+	}
+	if t.strictAuthParam {
+		return fmt.Errorf("%w %s", ErrAuthParamCollision, param)
+	}
+	if t.logger != nil {
+		t.log(r.Context(), slog.LevelWarn, "authorization parameter is replaced",
+			slog.String("event", "auth_param_collision"), slog.String("param", param))
+	}
+	return nil
+}
+
+// SessionKey returns current session key or empty string if session is not established
+func (t *Transport) SessionKey() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.session.key
+}
+
+// SessionExpiresAt returns time current session expires at
+// or zero time if session is not established
+func (t *Transport) SessionExpiresAt() time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.session.key) == 0 {
+		return time.Time{}
+	}
+	return t.session.start.Add(t.validFor())
+}
+
+// AuthCount returns number of successful authorizations made by transport,
+// constantly growing count points to re-authorization loop
+func (t *Transport) AuthCount() int64 {
+	return t.authCount.Load()
+}
+
+// LastAuthTime returns time of the last successful authorization
+// or zero time if transport has not authorized yet
+func (t *Transport) LastAuthTime() time.Time {
+	ns := t.lastAuth.Load()
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
+
+// Authenticate establishes session unless valid one already exists,
+// it allows to check credentials before making any API request.
+// Returned error is an *AuthError if API rejected authorization
+func (t *Transport) Authenticate(ctx context.Context) error {
+	if err := t.validate(); err != nil {
+		return fmt.Errorf("authenticate: invalid configuration: %v", err)
+	}
+	_, err := t.sessionKey(ctx)
+	return err
+}
+
+// RefreshSession drops current session, even valid one, and establishes
+// a new one. Returned error is an *AuthError if API rejected authorization
+func (t *Transport) RefreshSession(ctx context.Context) error {
+	if err := t.validate(); err != nil {
+		return fmt.Errorf("refresh session: invalid configuration: %v", err)
+	}
+	if len(t.token) > 0 {
+		return nil
+	}
+	if err := t.mu.LockContext(ctx); err != nil {
+		return &AuthError{Err: err}
+	}
+	defer t.unlock()
+	t.session.key = ""
+	return t.renew(ctx)
+}
+
+// Logout ends current session on the server, session is dropped
+// even if logout request fails. Without established session it does nothing
+func (t *Transport) Logout(ctx context.Context) error {
+	if err := t.mu.LockContext(ctx); err != nil {
+		return fmt.Errorf("logout: %w", err)
+	}
+	defer t.mu.Unlock()
+	key, host := t.session.key, t.session.host
+	if len(key) == 0 {
+		return nil
+	}
+	t.session.key = ""
+	t.session.start = time.Time{}
+	t.session.host = nil
+	if t.store != nil {
+		t.store.Save("", time.Time{})
+	}
+	if host == nil {
+		host = t.baseURL()
+	}
+	if err := t.logout(ctx, host, key); err != nil {
+		return fmt.Errorf("logout: %w", err)
+	}
+	return nil
+}
+
+// logout ends session with given key on the host it was issued by
+func (t *Transport) logout(ctx context.Context, host *url.URL, key string) error {
+	reqURL := joinPath(host, &url.URL{Path: "/api/logout/"})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, reqURL.String(), http.NoBody)
+	if err != nil {
+		return fmt.Errorf("could not create request: %v", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", t.ua())
+	t.applyDefaults(req)
+	t.authorize(req, key)
+
+	if err := t.wait(ctx); err != nil {
+		return err
+	}
+	res, err := t.exchange(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxErrorSize))
+	if err != nil {
+		return fmt.Errorf("could not read response: %v", err)
+	}
+	if res.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("invalid response: %d %s", res.StatusCode, http.StatusText(res.StatusCode))
+	}
+	if !looksJSON(body) {
+		return nonJSON(res, body)
+	}
+	er := errorResp{}
+	if err := json.Unmarshal(body, &er); err != nil {
+		return fmt.Errorf("could not decode response: %v", err)
+	}
+	if !er.Success {
+		return &APIError{Code: er.Code, Message: er.Message}
+	}
+	return nil
+}
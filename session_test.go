@@ -0,0 +1,357 @@
+package comagic
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nk2ge5k/go-api-comagic/comagictest"
+)
+
+// fakeHost is a server recording paths and session keys of requests
+type fakeHost struct {
+	*httptest.Server
+	// status of API responses, 200 if zero
+	status int
+	// loginDelay delays login responses
+	loginDelay time.Duration
+	// down makes host answer all requests with 503
+	down bool
+
+	mu       sync.Mutex
+	requests []string
+}
+
+func newFakeHost(t *testing.T) *fakeHost {
+	t.Helper()
+	h := &fakeHost{}
+	h.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.mu.Lock()
+		h.requests = append(h.requests, r.URL.Path+" "+r.URL.Query().Get("session_key"))
+		h.mu.Unlock()
+		switch {
+		case h.down:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case r.URL.Path == "/api/login/":
+			time.Sleep(h.loginDelay)
+			w.Write([]byte(`{"success":true,"data":{"session_key":"key-` + r.Host + `"}}`))
+		case h.status != 0:
+			w.WriteHeader(h.status)
+		default:
+			w.Write([]byte(`{"success":true,"data":[]}`))
+		}
+	}))
+	t.Cleanup(h.Close)
+	return h
+}
+
+func (h *fakeHost) URL() *url.URL {
+	u, _ := url.Parse(h.Server.URL)
+	return u
+}
+
+func (h *fakeHost) Requests() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]string(nil), h.requests...)
+}
+
+func TestLogout(t *testing.T) {
+	h := newFakeHost(t)
+	tr := New("login", "password", WithBaseURL(h.URL())).Transport.(*Transport)
+	ctx := context.Background()
+
+	if err := tr.Logout(ctx); err != nil {
+		t.Fatalf("logout without session: %v", err)
+	}
+	if n := len(h.Requests()); n != 0 {
+		t.Fatalf("got %d requests, logout without session must do nothing", n)
+	}
+	if err := tr.Authenticate(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	key := tr.SessionKey()
+	if err := tr.Logout(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	requests := h.Requests()
+	if got, want := requests[len(requests)-1], "/api/logout/ "+key; got != want {
+		t.Errorf("got request %q, want %q", got, want)
+	}
+	if len(tr.SessionKey()) > 0 || !tr.SessionExpiresAt().IsZero() {
+		t.Error("session is not cleared by logout")
+	}
+}
+
+func TestLogoutFailed(t *testing.T) {
+	h := newFakeHost(t)
+	h.status = http.StatusInternalServerError
+	tr := New("login", "password", WithBaseURL(h.URL())).Transport.(*Transport)
+	if err := tr.Authenticate(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := tr.Logout(context.Background()); err == nil {
+		t.Fatal("got no error of failed logout")
+	}
+	if len(tr.SessionKey()) > 0 {
+		t.Error("session is not cleared by failed logout")
+	}
+}
+
+func TestLogoutFailoverHost(t *testing.T) {
+	primary, fallback := newFakeHost(t), newFakeHost(t)
+	primary.down = true
+	c := New("login", "password", WithBaseURLs(primary.URL(), fallback.URL()))
+	req, _ := http.NewRequest(http.MethodGet, "/api/x/", nil)
+	res, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	res.Body.Close()
+
+	tr := c.Transport.(*Transport)
+	key := tr.SessionKey()
+	if err := tr.Logout(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	requests := fallback.Requests()
+	if got, want := requests[len(requests)-1], "/api/logout/ "+key; got != want {
+		t.Errorf("got last request of fallback host %q, want %q", got, want)
+	}
+	for _, r := range primary.Requests() {
+		if r == "/api/logout/ "+key {
+			t.Errorf("session of fallback host was ended on primary one")
+		}
+	}
+}
+
+func TestLogoutContext(t *testing.T) {
+	h := newFakeHost(t)
+	h.loginDelay = 500 * time.Millisecond
+	tr := New("login", "password", WithBaseURL(h.URL())).Transport.(*Transport)
+	go tr.Authenticate(context.Background())
+	for len(h.Requests()) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := tr.Logout(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got error %v, want context deadline", err)
+	}
+	if d := time.Since(start); d > 200*time.Millisecond {
+		t.Fatalf("logout waited %s for authorization", d)
+	}
+}
+
+func TestFileSessionStore(t *testing.T) {
+	s := &FileSessionStore{Path: filepath.Join(t.TempDir(), "session.json")}
+	if _, _, ok := s.Load(); ok {
+		t.Fatal("got session from missing file")
+	}
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	s.Save("key", start)
+	key, got, ok := s.Load()
+	if !ok || key != "key" || !got.Equal(start) {
+		t.Fatalf("got session %q started at %s (%v), want key started at %s", key, got, ok, start)
+	}
+	fi, err := os.Stat(s.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := fi.Mode().Perm(); perm != 0o600 {
+		t.Errorf("got file permissions %o, want 600", perm)
+	}
+}
+
+func TestSessionStore(t *testing.T) {
+	srv := comagictest.NewServer(t, comagictest.WithData("/api/x/", nil))
+	s := &FileSessionStore{Path: filepath.Join(t.TempDir(), "session.json")}
+	get := func(c *http.Client) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, "/api/x/", nil)
+		res, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		res.Body.Close()
+	}
+	get(New("login", "password", WithBaseURL(srv.URL()), WithSessionStore(s)))
+	if key, _, ok := s.Load(); !ok || key != comagictest.SessionKey {
+		t.Fatalf("got stored session key %q, want %q", key, comagictest.SessionKey)
+	}
+	get(New("login", "password", WithBaseURL(srv.URL()), WithSessionStore(s)))
+	if n := srv.Logins(); n != 1 {
+		t.Fatalf("got %d logins, want stored session reused", n)
+	}
+
+	// stale stored session is renewed and replaced
+	srv.ExpireSession()
+	get(New("login", "password", WithBaseURL(srv.URL()), WithSessionStore(s)))
+	if key, _, _ := s.Load(); key != srv.SessionKey() {
+		t.Errorf("got stored session key %q, want renewed %q", key, srv.SessionKey())
+	}
+}
+
+func TestSessionState(t *testing.T) {
+	clock := newFakeClock()
+	h := newFakeHost(t)
+	tr := New("login", "password", WithBaseURL(h.URL()), WithClock(clock.Now),
+		WithSessionLifetime(time.Hour), WithSessionSkew(time.Minute)).Transport.(*Transport)
+	if len(tr.SessionKey()) > 0 || !tr.SessionExpiresAt().IsZero() {
+		t.Fatal("got session state before authorization")
+	}
+	if err := tr.Authenticate(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := tr.SessionKey(), "key-"+h.URL().Host; got != want {
+		t.Errorf("got session key %q, want %q", got, want)
+	}
+	if got, want := tr.SessionExpiresAt(), clock.Now().Add(59*time.Minute); !got.Equal(want) {
+		t.Errorf("got session expiry %s, want %s", got, want)
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	srv := comagictest.NewServer(t)
+	tr := New("login", "password", WithBaseURL(srv.URL())).Transport.(*Transport)
+	for i := 0; i < 2; i++ {
+		if err := tr.Authenticate(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if n := srv.Logins(); n != 1 {
+		t.Errorf("got %d logins, want valid session reused", n)
+	}
+}
+
+func TestAuthenticateRejected(t *testing.T) {
+	base := newLoginServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"success":false,"message":"invalid login or password"}`))
+	})
+	tr := New("login", "password", WithBaseURL(base)).Transport.(*Transport)
+	err := tr.Authenticate(context.Background())
+	ae := &AuthError{}
+	if !errors.As(err, &ae) || !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("got error %v, want AuthError with ErrInvalidCredentials", err)
+	}
+	if len(tr.SessionKey()) > 0 {
+		t.Error("session is established by rejected authorization")
+	}
+}
+
+func TestRefreshSession(t *testing.T) {
+	srv := comagictest.NewServer(t)
+	tr := New("login", "password", WithBaseURL(srv.URL())).Transport.(*Transport)
+	ctx := context.Background()
+	if err := tr.Authenticate(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := tr.RefreshSession(ctx); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if n := srv.Logins(); n != 3 {
+		t.Fatalf("got %d logins, want valid session refreshed twice", n)
+	}
+}
+
+func TestRefreshSessionFailed(t *testing.T) {
+	base := newLoginServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"success":false,"message":"account is disabled"}`))
+	})
+	tr := New("login", "password", WithBaseURL(base)).Transport.(*Transport)
+	err := tr.RefreshSession(context.Background())
+	ae := &AuthError{}
+	if !errors.As(err, &ae) || ae.Message != "account is disabled" {
+		t.Fatalf("got error %v, want AuthError", err)
+	}
+}
+
+func TestAuthCount(t *testing.T) {
+	clock := newFakeClock()
+	h := newFakeHost(t)
+	tr := New("login", "password", WithBaseURL(h.URL()), WithClock(clock.Now)).Transport.(*Transport)
+	if tr.AuthCount() != 0 || !tr.LastAuthTime().IsZero() {
+		t.Fatalf("got %d authorizations at %s before the first one", tr.AuthCount(), tr.LastAuthTime())
+	}
+	ctx := context.Background()
+	if err := tr.Authenticate(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		clock.Advance(time.Minute)
+		if err := tr.RefreshSession(ctx); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if n := tr.AuthCount(); n != 3 {
+		t.Errorf("got %d authorizations, want 3", n)
+	}
+	if got := tr.LastAuthTime(); !got.Equal(clock.Now()) {
+		t.Errorf("got last authorization at %s, want %s", got, clock.Now())
+	}
+}
+
+func TestAuthParamCollision(t *testing.T) {
+	h := newFakeHost(t)
+	l, records := recordLogs(t)
+	c := New("login", "password", WithBaseURL(h.URL()), WithLogger(l))
+	req, _ := http.NewRequest(http.MethodGet, "/api/x/?session_key=copied&a=1", nil)
+	res, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	res.Body.Close()
+	if got := res.Request.URL.Query()["session_key"]; len(got) != 1 || got[0] != "key-"+h.URL().Host {
+		t.Errorf("got session keys %q, want transport one only", got)
+	}
+	warned := false
+	for _, rec := range records() {
+		if rec["event"] == "auth_param_collision" && rec["param"] == "session_key" && rec["level"] == "WARN" {
+			warned = true
+		}
+	}
+	if !warned {
+		t.Error("collision is not logged")
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, "/api/x/", nil)
+	res, err = c.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	res.Body.Close()
+	n := 0
+	for _, rec := range records() {
+		if rec["event"] == "auth_param_collision" {
+			n++
+		}
+	}
+	if n != 1 {
+		t.Errorf("got %d collision records, want one of request with session key", n)
+	}
+}
+
+func TestStrictAuthParam(t *testing.T) {
+	h := newFakeHost(t)
+	c := New("login", "password", WithBaseURL(h.URL()), WithStrictAuthParam())
+	req, _ := http.NewRequest(http.MethodGet, "/api/x/?session_key=copied", nil)
+	if _, err := c.Do(req); !errors.Is(err, ErrAuthParamCollision) {
+		t.Fatalf("got error %v, want ErrAuthParamCollision", err)
+	}
+	for _, r := range h.Requests() {
+		if r != "/api/login/ " {
+			t.Errorf("got request %q, want colliding request not sent", r)
+		}
+	}
+}
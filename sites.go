@@ -0,0 +1,38 @@
+package comagic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Site is a site configured in the account
+type Site struct {
+	ID            int64  `json:"id"`
+	Domain        string `json:"domain"`
+	Name          string `json:"name"`
+	DefaultNumber string `json:"default_number"`
+	// Extra holds response fields unknown to the package
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler interface
+func (s *Site) UnmarshalJSON(b []byte) error {
+	type plain Site
+	if err := json.Unmarshal(b, (*plain)(s)); err != nil {
+		return err
+	}
+	extra, err := unknownFields(b, s)
+	s.Extra = extra
+	return err
+}
+
+// Sites returns sites configured in the account,
+// following pages if API splits the list
+func (c *Client) Sites(ctx context.Context) ([]Site, error) {
+	sites, err := NewPageCursor(ctx, DefaultPageSize, pager[Site](c, "/api/sites/", pageQuery, true)).All()
+	if err != nil {
+		return nil, fmt.Errorf("sites: %w", err)
+	}
+	return sites, nil
+}
@@ -0,0 +1,40 @@
+package comagic
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestSites(t *testing.T) {
+	pages := map[string]string{
+		"":   `{"success":true,"next_page_token":"p2","data":[{"id":1,"domain":"example.com","name":"Example","default_number":"74950000001"}]}`,
+		"p2": `{"success":true,"data":[{"id":2,"domain":"example.org","name":"Example Org","default_number":"74950000002"}]}`,
+	}
+	base := newLoginServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case DefaultLoginPath:
+			w.Write([]byte(`{"success":true,"data":{"session_key":"key"}}`))
+		case "/api/sites/":
+			w.Write([]byte(pages[r.URL.Query().Get("page_token")]))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	c := NewClient(New("login", "password", WithBaseURL(base)))
+	sites, err := c.Sites(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(sites) != 2 {
+		t.Fatalf("got %d sites, want both pages", len(sites))
+	}
+	s := sites[0]
+	if s.ID != 1 || s.Domain != "example.com" || s.Name != "Example" || s.DefaultNumber != "74950000001" {
+		t.Errorf("got site %+v", s)
+	}
+	if sites[1].ID != 2 || sites[1].Domain != "example.org" {
+		t.Errorf("got site %+v of the second page", sites[1])
+	}
+}
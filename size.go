@@ -0,0 +1,70 @@
+package comagic
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// DefaultMaxResponseSize is a maximum size of response body read
+// by transport when none is set
+const DefaultMaxResponseSize = 64 << 20
+
+// ErrResponseTooLarge is returned when response body exceeds maximum size
+var ErrResponseTooLarge = errors.New("response body too large")
+
+// unlimitedKey is a context key disabling response size limit
+type unlimitedKey struct{}
+
+// WithMaxResponseSize is an option function for setting maximum size of
+// decompressed response body, reading beyond it fails with ErrResponseTooLarge.
+// Zero means DefaultMaxResponseSize and negative size disables limit.
+// Streaming Client methods, e.g. CallsReportStream and CallsReportCSV, are exempt
+func WithMaxResponseSize(n int64) func(*Transport) {
+	return func(t *Transport) { t.maxResponseSize = n }
+}
+
+// withoutSizeLimit returns context of request exempt from response size limit
+func withoutSizeLimit(ctx context.Context) context.Context {
+	return context.WithValue(ctx, unlimitedKey{}, true)
+}
+
+// limitBody wraps response body into reader failing beyond maximum size
+func (t *Transport) limitBody(r *http.Request, res *http.Response) {
+	n := t.maxResponseSize
+	if n == 0 {
+		n = DefaultMaxResponseSize
+	}
+	if n < 0 || r.Context().Value(unlimitedKey{}) != nil {
+		return
+	}
+	res.Body = &limitedBody{ReadCloser: res.Body, left: n}
+}
+
+// limitedBody fails with ErrResponseTooLarge when more than left bytes are read
+type limitedBody struct {
+	io.ReadCloser
+	left int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if b.left <= 0 {
+		// limit is reached, body is too large if it has anything else
+		var one [1]byte
+		n, err := b.ReadCloser.Read(one[:])
+		if n > 0 {
+			return 0, ErrResponseTooLarge
+		}
+		return 0, err
+	}
+	if int64(len(p)) > b.left {
+		p = p[:b.left]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.left -= int64(n)
+	return n, err
+}
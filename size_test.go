@@ -0,0 +1,68 @@
+package comagic
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nk2ge5k/go-api-comagic/comagictest"
+)
+
+func TestMaxResponseSize(t *testing.T) {
+	big := `{"success":true,"data":"` + strings.Repeat("a", 1000) + `"}`
+	srv := comagictest.NewServer(t,
+		comagictest.WithHandler("/api/x/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(big))
+		})),
+		comagictest.WithHandler("/api/calls_report/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/csv")
+			w.Write([]byte(strings.Repeat("1;2;3\n", 1000)))
+		})),
+	)
+	tests := []struct {
+		name   string
+		size   int64
+		tooBig bool
+	}{
+		{"body larger than limit", 100, true},
+		{"body of exactly limit size", int64(len(big)), false},
+		{"default limit", 0, false},
+		{"disabled limit", -1, false},
+	}
+	for _, tt := range tests {
+		c := NewClient(New("login", "password", WithBaseURL(srv.URL()), WithMaxResponseSize(tt.size)))
+		var out string
+		err := c.GetJSON(context.Background(), "/api/x/", nil, &out)
+		if tt.tooBig != errors.Is(err, ErrResponseTooLarge) || !tt.tooBig && err != nil {
+			t.Errorf("%s: got error %v", tt.name, err)
+		}
+	}
+
+	// streaming methods are exempt
+	c := NewClient(New("login", "password", WithBaseURL(srv.URL()), WithMaxResponseSize(100)))
+	from := time.Now().Add(-time.Hour)
+	buf := &bytes.Buffer{}
+	if err := c.CallsReportCSV(context.Background(), CallsReportRequest{DateFrom: from, DateTill: from.Add(time.Minute)}, buf); err != nil {
+		t.Fatalf("unexpected error of exempt export: %v", err)
+	}
+	if buf.Len() != 6000 {
+		t.Errorf("got %d bytes of export, want 6000", buf.Len())
+	}
+}
+
+func TestLimitedBody(t *testing.T) {
+	b := &limitedBody{ReadCloser: io.NopCloser(strings.NewReader("abcdef")), left: 4}
+	got, err := io.ReadAll(b)
+	if !errors.Is(err, ErrResponseTooLarge) || string(got) != "abcd" {
+		t.Fatalf("got %q and error %v, want 4 bytes and ErrResponseTooLarge", got, err)
+	}
+	b = &limitedBody{ReadCloser: io.NopCloser(strings.NewReader("abcd")), left: 4}
+	if got, err := io.ReadAll(b); err != nil || string(got) != "abcd" {
+		t.Fatalf("got %q and error %v, want body of limit size read", got, err)
+	}
+}
@@ -0,0 +1,145 @@
+package comagic
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// getStream makes GET request to API endpoint and calls item for every element
+// of response data array without buffering whole response in memory
+func (c *Client) getStream(ctx context.Context, path string, query url.Values, item func(dec JSONDecoder) error) error {
+	u := &url.URL{Path: path, RawQuery: query.Encode()}
+	req, err := http.NewRequestWithContext(withoutSizeLimit(ctx), http.MethodGet, u.String(), nil)
+	if err != nil {
+		return fmt.Errorf("could not create request: %v", err)
+	}
+	res, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusTooManyRequests {
+		return rateLimited(res, time.Now())
+	}
+	br := bufio.NewReader(res.Body)
+	if head, _ := br.Peek(snippetSize); !looksJSON(head) || res.StatusCode >= http.StatusBadRequest {
+		// error payloads are small, so they are reported as usual
+		res.Body = io.NopCloser(br)
+		_, err := c.decodeEnvelope(res)
+		return err
+	}
+	return decodeStream(c.codec().NewDecoder(br), item)
+}
+
+// getExport makes GET request to API endpoint asking for given representation
+// and copies response body into w as is, response headers and number of bytes
+// written are returned. Error envelope is returned as JSON regardless of
+// requested representation, so it is decoded as usual
+func (c *Client) getExport(ctx context.Context, path string, query url.Values, accept string, w io.Writer) (http.Header, int64, error) {
+	u := &url.URL{Path: path, RawQuery: query.Encode()}
+	req, err := http.NewRequestWithContext(withoutSizeLimit(ctx), http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not create request: %v", err)
+	}
+	req.Header.Set("Accept", accept)
+	res, err := c.do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusTooManyRequests {
+		return nil, 0, rateLimited(res, time.Now())
+	}
+	br := bufio.NewReader(res.Body)
+	// bot challenge page may come with 200 status instead of asked representation
+	if head, _ := br.Peek(br.Size()); challenged(res.Header.Get("Content-Type"), head) {
+		return nil, 0, nonJSON(res, head)
+	}
+	if head, _ := br.Peek(snippetSize); looksJSON(head) || res.StatusCode >= http.StatusBadRequest {
+		res.Body = io.NopCloser(br)
+		if _, err := c.decodeEnvelope(res); err != nil {
+			return nil, 0, err
+		}
+		return nil, 0, fmt.Errorf("unexpected JSON response instead of %s", accept)
+	}
+	n, err := io.Copy(w, br)
+	if err != nil {
+		return nil, n, fmt.Errorf("could not copy response: %v", err)
+	}
+	return res.Header, n, nil
+}
+
+// decodeStream walks response envelope calling item for every element of data array
+func decodeStream(dec JSONDecoder, item func(dec JSONDecoder) error) error {
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+	env := apiResp{}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("could not decode response: %v", err)
+		}
+		switch tok {
+		case "data":
+			if err := decodeItems(dec, item); err != nil {
+				return err
+			}
+		case "success":
+			err = dec.Decode(&env.Success)
+		case "code":
+			err = dec.Decode(&env.Code)
+		case "message":
+			err = dec.Decode(&env.Message)
+		default:
+			err = dec.Decode(&json.RawMessage{})
+		}
+		if err != nil {
+			return fmt.Errorf("could not decode response: %v", err)
+		}
+	}
+	if err := expectDelim(dec, '}'); err != nil {
+		return err
+	}
+	if !env.Success {
+		return &APIError{Code: env.Code, Message: env.Message}
+	}
+	return nil
+}
+
+// decodeItems decodes data array element by element, null data is skipped
+func decodeItems(dec JSONDecoder, item func(dec JSONDecoder) error) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("could not decode response data: %v", err)
+	}
+	if tok == nil {
+		return nil
+	}
+	if tok != json.Delim('[') {
+		return fmt.Errorf("could not decode response data: unexpected %v", tok)
+	}
+	for dec.More() {
+		if err := item(dec); err != nil {
+			return err
+		}
+	}
+	return expectDelim(dec, ']')
+}
+
+func expectDelim(dec JSONDecoder, d json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return fmt.Errorf("could not decode response: %v", err)
+	}
+	if tok != d {
+		return fmt.Errorf("could not decode response: expected %v, got %v", d, tok)
+	}
+	return nil
+}
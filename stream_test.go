@@ -0,0 +1,123 @@
+package comagic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/nk2ge5k/go-api-comagic/comagictest"
+)
+
+// streamServer serves calls report of n records, after half of records are
+// written it waits until the client got the first one, so report is never
+// served completely to the client buffering it
+func streamServer(t *testing.T, n int, first <-chan struct{}) *comagictest.Server {
+	return comagictest.NewServer(t, comagictest.WithHandler("/api/calls_report/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"success":true,"data":[`)
+		for i := 0; i < n; i++ {
+			if i > 0 {
+				fmt.Fprint(w, ",")
+			}
+			fmt.Fprintf(w, `{"id":%d,"call_date":"2024-01-01 10:00:00","numa":"74950000000","numb":"74950000001","duration":60,"tags":[{"tag_id":1,"tag_name":"lead"}]}`, i)
+			if i == n/2 && first != nil {
+				w.(http.Flusher).Flush()
+				select {
+				case <-first:
+				case <-time.After(5 * time.Second):
+					t.Error("client did not get the first record before the whole report was sent")
+				}
+			}
+		}
+		fmt.Fprint(w, `]}`)
+	})))
+}
+
+func TestCallsReportStream(t *testing.T) {
+	const records = 10000
+	first := make(chan struct{})
+	srv := streamServer(t, records, first)
+	c := NewClient(New("login", "password", WithBaseURL(srv.URL())))
+	req := CallsReportRequest{DateFrom: time.Now().Add(-time.Hour), DateTill: time.Now()}
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	n := 0
+	err := c.CallsReportStream(context.Background(), req, func(call Call) error {
+		if call.ID != int64(n) {
+			return fmt.Errorf("got call %d, want %d", call.ID, n)
+		}
+		if n == 0 {
+			close(first)
+		}
+		if n++; n == records {
+			runtime.GC()
+			runtime.ReadMemStats(&after)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != records {
+		t.Fatalf("got %d calls, want %d", n, records)
+	}
+	// whole report decoded into memory takes several megabytes
+	if grown := int64(after.HeapAlloc) - int64(before.HeapAlloc); grown > 1<<20 {
+		t.Errorf("heap grew by %d bytes while streaming, want records not retained", grown)
+	}
+}
+
+func TestCallsReportStreamAllocs(t *testing.T) {
+	const records = 10000
+	srv := streamServer(t, records, nil)
+	c := NewClient(New("login", "password", WithBaseURL(srv.URL())))
+	req := CallsReportRequest{DateFrom: time.Now().Add(-time.Hour), DateTill: time.Now()}
+	perRecord := testing.AllocsPerRun(1, func() {
+		if err := c.CallsReportStream(context.Background(), req, func(Call) error { return nil }); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}) / records
+	if perRecord > 64 {
+		t.Errorf("got %.1f allocations per record, want bounded", perRecord)
+	}
+}
+
+func TestCallsReportStreamCallbackError(t *testing.T) {
+	srv := streamServer(t, 100, nil)
+	c := NewClient(New("login", "password", WithBaseURL(srv.URL())))
+	errStop := errors.New("stop")
+	n := 0
+	err := c.CallsReportStream(context.Background(), CallsReportRequest{DateFrom: time.Now().Add(-time.Hour), DateTill: time.Now()}, func(Call) error {
+		if n++; n == 10 {
+			return errStop
+		}
+		return nil
+	})
+	if err != errStop {
+		t.Fatalf("got error %v, want callback error as is", err)
+	}
+	if n != 10 {
+		t.Errorf("got %d calls, want iteration stopped by callback", n)
+	}
+}
+
+func TestCallsReportStreamAPIError(t *testing.T) {
+	srv := comagictest.NewServer(t, comagictest.WithHandler("/api/calls_report/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		comagictest.RespondError(w, "invalid_period", "period is too long")
+	})))
+	c := NewClient(New("login", "password", WithBaseURL(srv.URL())))
+	err := c.CallsReportStream(context.Background(), CallsReportRequest{DateFrom: time.Now().Add(-time.Hour), DateTill: time.Now()}, func(Call) error {
+		t.Error("callback is called for error response")
+		return nil
+	})
+	ae := &APIError{}
+	if !errors.As(err, &ae) || ae.Code != "invalid_period" {
+		t.Fatalf("got error %v, want invalid_period APIError", err)
+	}
+}
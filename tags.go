@@ -0,0 +1,54 @@
+package comagic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Tag is a tag which could be attached to calls
+type Tag struct {
+	ID    int64  `json:"id"`
+	Name  string `json:"name"`
+	Color string `json:"color"`
+	// Extra holds response fields unknown to the package
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler interface
+func (t *Tag) UnmarshalJSON(b []byte) error {
+	type plain Tag
+	if err := json.Unmarshal(b, (*plain)(t)); err != nil {
+		return err
+	}
+	extra, err := unknownFields(b, t)
+	t.Extra = extra
+	return err
+}
+
+// Tags returns tags of the account
+func (c *Client) Tags(ctx context.Context) ([]Tag, error) {
+	tags := []Tag{}
+	if err := c.getReference(ctx, "/api/tags/", nil, &tags); err != nil {
+		return nil, fmt.Errorf("tags: %w", err)
+	}
+	return tags, nil
+}
+
+// SetCallTags replaces tags attached to the call with given ones.
+// If API rejects the change error is an *APIError
+func (c *Client) SetCallTags(ctx context.Context, callID int64, tagIDs []int64) error {
+	if tagIDs == nil {
+		tagIDs = []int64{}
+	}
+	body := setCallTagsRequest{CallID: callID, TagIDs: tagIDs}
+	if err := c.PostJSON(ctx, "/api/tags/", body, nil); err != nil {
+		return fmt.Errorf("set call tags: %w", err)
+	}
+	return nil
+}
+
+type setCallTagsRequest struct {
+	CallID int64   `json:"call_id"`
+	TagIDs []int64 `json:"tag_ids"`
+}
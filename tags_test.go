@@ -0,0 +1,64 @@
+package comagic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/nk2ge5k/go-api-comagic/comagictest"
+)
+
+func TestTags(t *testing.T) {
+	srv := comagictest.NewServer(t, comagictest.WithData("/api/tags/", []map[string]interface{}{
+		{"id": 1, "name": "lead", "color": "#00ff00"},
+		{"id": 2, "name": "spam", "color": "#ff0000"},
+	}))
+	c := NewClient(New("login", "password", WithBaseURL(srv.URL())))
+	tags, err := c.Tags(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(tags) != 2 || tags[0].ID != 1 || tags[0].Name != "lead" || tags[1].Color != "#ff0000" {
+		t.Fatalf("got tags %+v, want lead and spam", tags)
+	}
+}
+
+func TestSetCallTags(t *testing.T) {
+	var got setCallTagsRequest
+	srv := comagictest.NewServer(t, comagictest.WithHandler("/api/tags/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("got %s request, want POST", r.Method)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("invalid request body: %v", err)
+		}
+		comagictest.Respond(w, nil)
+	})))
+	c := NewClient(New("login", "password", WithBaseURL(srv.URL())))
+	if err := c.SetCallTags(context.Background(), 10, []int64{1, 2}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.CallID != 10 || len(got.TagIDs) != 2 || got.TagIDs[0] != 1 || got.TagIDs[1] != 2 {
+		t.Fatalf("got request %+v, want call 10 with tags 1 and 2", got)
+	}
+	if err := c.SetCallTags(context.Background(), 10, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.TagIDs == nil || len(got.TagIDs) != 0 {
+		t.Errorf("got tag ids %v, want empty list clearing tags", got.TagIDs)
+	}
+}
+
+func TestSetCallTagsAPIError(t *testing.T) {
+	srv := comagictest.NewServer(t, comagictest.WithHandler("/api/tags/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		comagictest.RespondError(w, "call_not_found", "call 10 is not found")
+	})))
+	c := NewClient(New("login", "password", WithBaseURL(srv.URL())))
+	err := c.SetCallTags(context.Background(), 10, []int64{1})
+	ae := &APIError{}
+	if !errors.As(err, &ae) || ae.Code != "call_not_found" {
+		t.Fatalf("got error %v, want call_not_found APIError", err)
+	}
+}
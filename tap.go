@@ -0,0 +1,47 @@
+package comagic
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// WithResponseTap is an option function for setting callback receiving
+// copy of every API response body, which is useful for debugging decoding errors.
+// Response body is buffered in memory when tap is set, so it should not be used
+// for streaming large responses. Callback receives copy of the sent request
+// with session key and access token masked as "***" the same way they are logged,
+// it must not retain request
+func WithResponseTap(fn func(req *http.Request, body []byte)) func(*Transport) {
+	return func(t *Transport) { t.tap = fn }
+}
+
+// tapResponse buffers response body, passes its copy to the tap
+// and replaces body with buffered one
+func (t *Transport) tapResponse(r *http.Request, res *http.Response) error {
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return fmt.Errorf("round trip: could not read response: %w", err)
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+	t.tap(t.redactRequest(r), append([]byte(nil), body...))
+	return nil
+}
+
+// redactRequest returns copy of the sent request with secrets
+// of its URL and authorization headers masked
+func (t *Transport) redactRequest(r *http.Request) *http.Request {
+	c := r.Clone(r.Context())
+	if u, err := url.Parse(t.redactURL(r.URL)); err == nil {
+		c.URL = u
+	}
+	for _, h := range []string{"Authorization", t.sessionHeader} {
+		if len(h) > 0 && len(c.Header.Get(h)) > 0 {
+			c.Header.Set(h, "***")
+		}
+	}
+	return c
+}
@@ -0,0 +1,59 @@
+package comagic
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/nk2ge5k/go-api-comagic/comagictest"
+)
+
+func TestResponseTap(t *testing.T) {
+	srv := comagictest.NewServer(t, comagictest.WithData("/api/x/", map[string]int{"n": 1}))
+	var tapped [][]byte
+	var paths []string
+	var queries []url.Values
+	c := New("login", "password", WithBaseURL(srv.URL()), WithResponseTap(func(req *http.Request, body []byte) {
+		paths = append(paths, req.URL.Path)
+		queries = append(queries, req.URL.Query())
+		tapped = append(tapped, body)
+	}))
+	req, _ := http.NewRequest(http.MethodGet, "/api/x/", nil)
+	res, err := c.Do(req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("could not read body: %v", err)
+	}
+	if len(tapped) != 1 || string(tapped[0]) != string(body) {
+		t.Fatalf("got tapped bodies %q, want the one read by caller %q", tapped, body)
+	}
+	if paths[0] != "/api/x/" {
+		t.Errorf("got tapped request path %q, want /api/x/", paths[0])
+	}
+	if got := queries[0].Get("session_key"); got != "***" {
+		t.Errorf("got tapped session key %q, want it masked", got)
+	}
+	if got := res.Request.URL.Query().Get("session_key"); got == "***" || len(got) == 0 {
+		t.Errorf("got session key %q of sent request, want it intact", got)
+	}
+
+	// tap gets a copy, so changing it does not affect decoding
+	cl := NewClient(New("login", "password", WithBaseURL(srv.URL()), WithResponseTap(func(req *http.Request, body []byte) {
+		for i := range body {
+			body[i] = 'x'
+		}
+	})))
+	out := map[string]int{}
+	if err := cl.GetJSON(context.Background(), "/api/x/", nil, &out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out["n"] != 1 {
+		t.Errorf("got %v, want n=1", out)
+	}
+}
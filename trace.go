@@ -0,0 +1,62 @@
+package comagic
+
+import (
+	"context"
+	"net/http"
+)
+
+// Tracer starts spans around API and authorization requests.
+// It mirrors the subset of OpenTelemetry tracer API used by transport,
+// so OpenTelemetry tracer could be plugged in with a thin adapter.
+// Package does not depend on OpenTelemetry, so there is no option
+// accepting trace.TracerProvider: Tracer adapter of provider's tracer
+// starts trace.Span and maps SetAttribute to attribute.KeyValue
+type Tracer interface {
+	// Start starts span with given name as a child of span in context
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a single traced operation
+type Span interface {
+	SetAttribute(key string, value interface{})
+	RecordError(err error)
+	End()
+}
+
+// WithTracer is an option function for setting tracer. Transport records
+// comagic.request span for every request with comagic.auth child span
+// for authorization requests made for it. Spans are started with request
+// context, so they are children of span of the caller. Request span has
+// comagic.path, http.status_code and comagic.reauth attributes.
+// Without tracer spans are not recorded at all
+func WithTracer(tr Tracer) func(*Transport) {
+	return func(t *Transport) { t.tracer = tr }
+}
+
+func (t *Transport) startSpan(ctx context.Context, name string) (context.Context, Span) {
+	if t.tracer == nil {
+		return ctx, noopSpan{}
+	}
+	ctx, span := t.tracer.Start(ctx, name)
+	if id := CorrelationIDFromContext(ctx); len(id) > 0 {
+		span.SetAttribute("comagic.correlation_id", id)
+	}
+	return ctx, span
+}
+
+// endSpan records request result and ends span
+func endSpan(span Span, res *http.Response, err error) {
+	if res != nil {
+		span.SetAttribute("http.status_code", res.StatusCode)
+	}
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttribute(string, interface{}) {}
+func (noopSpan) RecordError(error)                {}
+func (noopSpan) End()                             {}
@@ -0,0 +1,148 @@
+package comagic
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/nk2ge5k/go-api-comagic/comagictest"
+)
+
+type recordedSpan struct {
+	name   string
+	parent *recordedSpan
+	attrs  map[string]interface{}
+	errs   []error
+	ended  bool
+}
+
+type spanKey struct{}
+
+// recordingTracer is a Tracer keeping all started spans
+type recordingTracer struct {
+	mu    sync.Mutex
+	spans []*recordedSpan
+}
+
+func (tr *recordingTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	parent, _ := ctx.Value(spanKey{}).(*recordedSpan)
+	s := &recordedSpan{name: name, parent: parent, attrs: map[string]interface{}{}}
+	tr.mu.Lock()
+	tr.spans = append(tr.spans, s)
+	tr.mu.Unlock()
+	return context.WithValue(ctx, spanKey{}, s), &tracedSpan{tr: tr, s: s}
+}
+
+func (tr *recordingTracer) named(name string) []*recordedSpan {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	var spans []*recordedSpan
+	for _, s := range tr.spans {
+		if s.name == name {
+			spans = append(spans, s)
+		}
+	}
+	return spans
+}
+
+type tracedSpan struct {
+	tr *recordingTracer
+	s  *recordedSpan
+}
+
+func (s *tracedSpan) SetAttribute(key string, value interface{}) {
+	s.tr.mu.Lock()
+	defer s.tr.mu.Unlock()
+	s.s.attrs[key] = value
+}
+
+func (s *tracedSpan) RecordError(err error) {
+	s.tr.mu.Lock()
+	defer s.tr.mu.Unlock()
+	s.s.errs = append(s.s.errs, err)
+}
+
+func (s *tracedSpan) End() {
+	s.tr.mu.Lock()
+	defer s.tr.mu.Unlock()
+	s.s.ended = true
+}
+
+func TestTracer(t *testing.T) {
+	srv := comagictest.NewServer(t, comagictest.WithData("/api/x/", nil))
+	tracer := &recordingTracer{}
+	c := New("login", "password", WithBaseURL(srv.URL()), WithTracer(tracer))
+
+	root := &recordedSpan{name: "caller"}
+	ctx := context.WithValue(context.Background(), spanKey{}, root)
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "/api/x/", nil)
+		res, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		res.Body.Close()
+	}
+
+	auths := tracer.named("comagic.auth")
+	if len(auths) != 1 {
+		t.Fatalf("got %d comagic.auth spans, want exactly one for login", len(auths))
+	}
+	requests := tracer.named("comagic.request")
+	if len(requests) != 2 {
+		t.Fatalf("got %d comagic.request spans, want 2", len(requests))
+	}
+	if auths[0].parent != requests[0] || !auths[0].ended {
+		t.Error("comagic.auth span is not ended child of request span")
+	}
+	for _, s := range requests {
+		if s.parent != root {
+			t.Error("request span is not child of caller's span")
+		}
+		if !s.ended {
+			t.Error("request span is not ended")
+		}
+		if s.attrs["comagic.path"] != "/api/x/" || s.attrs["http.status_code"] != http.StatusOK {
+			t.Errorf("got request span attributes %v, want path and status code", s.attrs)
+		}
+	}
+}
+
+func TestTracerReauth(t *testing.T) {
+	srv := comagictest.NewServer(t, comagictest.WithData("/api/x/", nil))
+	tracer := &recordingTracer{}
+	c := New("login", "password", WithBaseURL(srv.URL()), WithTracer(tracer))
+	for i := 0; i < 2; i++ {
+		if i == 1 {
+			srv.ExpireSession()
+		}
+		req, _ := http.NewRequest(http.MethodGet, "/api/x/", nil)
+		res, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		res.Body.Close()
+	}
+	if n := len(tracer.named("comagic.auth")); n != 2 {
+		t.Fatalf("got %d comagic.auth spans, want login and re-auth", n)
+	}
+	requests := tracer.named("comagic.request")
+	if _, ok := requests[0].attrs["comagic.reauth"]; ok {
+		t.Error("first request is marked as re-authorized")
+	}
+	if requests[1].attrs["comagic.reauth"] != true {
+		t.Errorf("got attributes %v of re-authorized request, want comagic.reauth", requests[1].attrs)
+	}
+}
+
+func TestNoopTracer(t *testing.T) {
+	ctx := context.Background()
+	got, span := (&Transport{}).startSpan(ctx, "comagic.request")
+	if got != ctx {
+		t.Error("transport without tracer changed context")
+	}
+	if _, ok := span.(noopSpan); !ok {
+		t.Errorf("got span %T, want noop span", span)
+	}
+}
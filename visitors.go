@@ -0,0 +1,138 @@
+package comagic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"time"
+)
+
+// VisitorSessionRequest is a visitor sessions report request parameters
+type VisitorSessionRequest struct {
+	DateFrom time.Time
+	DateTill time.Time
+	// Offset and Limit used for pagination, zero limit means API default
+	Offset int
+	Limit  int
+}
+
+// VisitorSessionResponse is a visitor sessions report
+type VisitorSessionResponse struct {
+	Sessions []VisitorSession
+}
+
+// VisitorSession is a site visit session
+type VisitorSession struct {
+	ID           int64    `json:"id"`
+	VisitorID    int64    `json:"visitor_id"`
+	SessionStart DateTime `json:"session_start"`
+	SiteID       int64    `json:"site_id"`
+	CampaignID   int64    `json:"ac_id"`
+	LandingPage  string   `json:"landing_page"`
+	Referrer     string   `json:"referrer"`
+	UTM          UTM      `json:"utm"`
+	HasCall      bool     `json:"has_call"`
+	// Extra holds response fields unknown to the package
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler interface
+func (s *VisitorSession) UnmarshalJSON(b []byte) error {
+	type plain VisitorSession
+	if err := json.Unmarshal(b, (*plain)(s)); err != nil {
+		return err
+	}
+	// API either sends UTM parameters in nested object or as flat
+	// session fields, nested object takes precedence
+	var flat flatUTM
+	if err := json.Unmarshal(b, &flat); err != nil {
+		return err
+	}
+	s.UTM = s.UTM.or(UTM(flat))
+	extra, err := unknownFields(b, s)
+	for _, name := range utmFields {
+		delete(extra, name)
+	}
+	if len(extra) == 0 {
+		extra = nil
+	}
+	s.Extra = extra
+	return err
+}
+
+// UTM is a UTM parameters of the visit
+type UTM struct {
+	Source   string `json:"source"`
+	Medium   string `json:"medium"`
+	Campaign string `json:"campaign"`
+	Term     string `json:"term"`
+	Content  string `json:"content"`
+}
+
+// flatUTM is a UTM parameters with "utm_" prefixed names
+type flatUTM struct {
+	Source   string `json:"utm_source"`
+	Medium   string `json:"utm_medium"`
+	Campaign string `json:"utm_campaign"`
+	Term     string `json:"utm_term"`
+	Content  string `json:"utm_content"`
+}
+
+// utmFields are names of flat UTM fields of the session
+var utmFields = jsonNames(reflect.TypeOf(flatUTM{}))
+
+// UnmarshalJSON implements json.Unmarshaler interface,
+// names of parameters could be either with or without "utm_" prefix
+func (u *UTM) UnmarshalJSON(b []byte) error {
+	type plain UTM
+	var v plain
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	var flat flatUTM
+	if err := json.Unmarshal(b, &flat); err != nil {
+		return err
+	}
+	*u = UTM(v).or(UTM(flat))
+	return nil
+}
+
+// IsZero returns true if none of parameters is set
+func (u UTM) IsZero() bool {
+	return u == UTM{}
+}
+
+// or returns parameters of u with empty ones taken from other
+func (u UTM) or(other UTM) UTM {
+	def := func(a, b string) string {
+		if len(a) > 0 {
+			return a
+		}
+		return b
+	}
+	return UTM{
+		Source:   def(u.Source, other.Source),
+		Medium:   def(u.Medium, other.Medium),
+		Campaign: def(u.Campaign, other.Campaign),
+		Term:     def(u.Term, other.Term),
+		Content:  def(u.Content, other.Content),
+	}
+}
+
+// VisitorSessions returns site visit sessions started in requested period
+func (c *Client) VisitorSessions(ctx context.Context, req VisitorSessionRequest) (VisitorSessionResponse, error) {
+	resp := VisitorSessionResponse{Sessions: []VisitorSession{}}
+	if err := c.validatePeriod(req.DateFrom, req.DateTill); err != nil {
+		return resp, fmt.Errorf("visitor sessions: %w", err)
+	}
+	sessions, err := getPaged[VisitorSession](ctx, c, "/api/session_report/", func(offset, limit int) url.Values {
+		return reportQuery(c.loc, req.DateFrom, req.DateTill, offset, limit)
+	}, req.Offset, req.Limit)
+	if err != nil {
+		return resp, fmt.Errorf("visitor sessions: %w", err)
+	}
+	resp.Sessions = sessions
+	return resp, nil
+}
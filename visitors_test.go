@@ -0,0 +1,85 @@
+package comagic
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/nk2ge5k/go-api-comagic/comagictest"
+)
+
+func TestVisitorSessions(t *testing.T) {
+	var query url.Values
+	srv := comagictest.NewServer(t, comagictest.WithHandler("/api/session_report/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		comagictest.Respond(w, []map[string]interface{}{{
+			"id": 10, "visitor_id": 20, "session_start": "2024-01-01 09:00:00", "site_id": 2, "ac_id": 7,
+			"landing_page": "https://example.com/?utm_source=google", "referrer": "https://google.com/",
+			"utm":      map[string]string{"source": "google", "medium": "cpc", "campaign": "winter"},
+			"has_call": true,
+			"device":   "mobile",
+		}})
+	})))
+	c := NewClient(New("login", "password", WithBaseURL(srv.URL())))
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, DefaultLocation)
+	resp, err := c.VisitorSessions(context.Background(), VisitorSessionRequest{DateFrom: from, DateTill: from.AddDate(0, 0, 1), Offset: 100, Limit: 50})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if query.Get("date_from") != "2024-01-01 00:00:00" || query.Get("offset") != "100" || query.Get("limit") != "50" {
+		t.Errorf("got query %v, want period and pagination", query)
+	}
+	if len(resp.Sessions) != 1 {
+		t.Fatalf("got %d sessions, want 1", len(resp.Sessions))
+	}
+	s := resp.Sessions[0]
+	if s.ID != 10 || s.VisitorID != 20 || s.SiteID != 2 || s.CampaignID != 7 || !s.HasCall ||
+		s.LandingPage != "https://example.com/?utm_source=google" || s.Referrer != "https://google.com/" {
+		t.Errorf("got session %+v", s)
+	}
+	if !s.SessionStart.Equal(from.Add(9 * time.Hour)) {
+		t.Errorf("got session start %s, want 09:00", s.SessionStart.Time)
+	}
+	if s.UTM != (UTM{Source: "google", Medium: "cpc", Campaign: "winter"}) {
+		t.Errorf("got UTM %+v", s.UTM)
+	}
+	if string(s.Extra["device"]) != `"mobile"` || len(s.Extra) != 1 {
+		t.Errorf("got extra %v, want only unknown device field", s.Extra)
+	}
+}
+
+func TestVisitorSessionUTM(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		want UTM
+	}{
+		{"flat", `{"id":1,"utm_source":"yandex","utm_medium":"cpc","utm_campaign":"spring","utm_term":"phone","utm_content":"ad1"}`,
+			UTM{Source: "yandex", Medium: "cpc", Campaign: "spring", Term: "phone", Content: "ad1"}},
+		{"nested", `{"id":1,"utm":{"source":"google","medium":"organic","term":"call"}}`,
+			UTM{Source: "google", Medium: "organic", Term: "call"}},
+		{"nested prefixed", `{"id":1,"utm":{"utm_source":"google","utm_content":"banner"}}`,
+			UTM{Source: "google", Content: "banner"}},
+		{"nested over flat", `{"id":1,"utm":{"source":"google"},"utm_source":"yandex","utm_medium":"cpc"}`,
+			UTM{Source: "google", Medium: "cpc"}},
+		{"missing", `{"id":1}`, UTM{}},
+	}
+	for _, tt := range tests {
+		var s VisitorSession
+		if err := json.Unmarshal([]byte(tt.json), &s); err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		if s.UTM != tt.want {
+			t.Errorf("%s: got UTM %+v, want %+v", tt.name, s.UTM, tt.want)
+		}
+		if s.UTM.IsZero() != (tt.want == UTM{}) {
+			t.Errorf("%s: got IsZero %v", tt.name, s.UTM.IsZero())
+		}
+		if len(s.Extra) > 0 {
+			t.Errorf("%s: got extra %v, want UTM fields not kept as unknown", tt.name, s.Extra)
+		}
+	}
+}